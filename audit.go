@@ -0,0 +1,302 @@
+package sqlutils
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAuditTable is the table TableAuditSink writes to when none is given.
+const DefaultAuditTable = "sqlutils_audit"
+
+// AuditEntry records one change made by the helpers. Record changes get one
+// entry per affected row with its values before and after the change, schema
+// changes one entry for the table.
+type AuditEntry struct {
+	Actor      string      `json:"actor,omitempty"`
+	Time       time.Time   `json:"time"`
+	Operation  Operation   `json:"operation"`
+	Table      string      `json:"table"`
+	PrimaryKey TableRecord `json:"primary_key,omitempty"`
+	Before     TableRecord `json:"before,omitempty"`
+	After      TableRecord `json:"after,omitempty"`
+	// Details holds what does not fit the other fields, such as the new name
+	// of a renamed or duplicated table.
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// AuditSink stores audit entries. A sink failing makes the helper return an
+// error, the change itself has already been applied by then.
+type AuditSink interface {
+	WriteAudit(entry AuditEntry) error
+}
+
+// Audit sinks registered per connection with SetAuditSink.
+var auditSinks sync.Map
+
+// SetAuditSink records the changes made through the connection to the sink,
+// a nil sink stops the auditing. Sinks passed with WithAudit apply on top of
+// it.
+func SetAuditSink(db *sql.DB, sink AuditSink) {
+	if sink == nil {
+		auditSinks.Delete(db)
+		return
+	}
+	auditSinks.Store(db, sink)
+}
+
+// WithAudit records the changes made by the call to the sink, on top of the
+// sink registered for the connection.
+func WithAudit(sink AuditSink) Option {
+	return func(o *options) {
+		o.auditSinks = append(o.auditSinks, sink)
+	}
+}
+
+// WithActor names who makes the call in the audit entries.
+func WithActor(actor string) Option {
+	return func(o *options) {
+		o.actor = actor
+	}
+}
+
+// Keeps a helper called by another one from auditing the same change twice
+// and from filling its undo token.
+func withoutChangeTracking() Option {
+	return func(o *options) {
+		o.skipAudit = true
+		o.undo = nil
+	}
+}
+
+func collectAuditSinks(o *options) []AuditSink {
+	if o.skipAudit || o.dryRun != nil {
+		return nil
+	}
+
+	var sinks []AuditSink
+	if sink, ok := auditSinks.Load(o.db); ok {
+		sinks = append(sinks, sink.(AuditSink))
+	}
+	return append(sinks, o.auditSinks...)
+}
+
+func auditing(o *options) bool {
+	return len(collectAuditSinks(o)) != 0
+}
+
+// Completes the entries with the details of the call and hands them to the
+// sinks.
+func writeAudit(o *options, entries ...AuditEntry) error {
+	sinks := collectAuditSinks(o)
+	if len(sinks) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	for _, entry := range entries {
+		entry.Actor = o.actor
+		entry.Time = now
+		entry.Operation = o.operation
+		if entry.Table == "" {
+			entry.Table = o.table
+		}
+
+		for _, sink := range sinks {
+			if err := sink.WriteAudit(entry); err != nil {
+				return fmt.Errorf("writing audit entry: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Picks the primary key values out of a row, nil when the table has none.
+func primaryKeyOf(record TableRecord, primaryKeys []string) TableRecord {
+	if len(primaryKeys) == 0 {
+		return nil
+	}
+
+	primaryKey := make(TableRecord, len(primaryKeys))
+	for _, key := range primaryKeys {
+		primaryKey[key] = record[key]
+	}
+	return primaryKey
+}
+
+// TableAuditSink writes the entries to a table of the database, created with
+// the first entry when missing. Values are stored as JSON. Its statements
+// reach the hooks as OpWriteAudit. Entries are written once the change is
+// committed, not in its transaction: when writing fails the change stays
+// unaudited and the helper returns the error.
+type TableAuditSink struct {
+	DB           *sql.DB
+	DatabaseType DatabaseType
+	// TableName is DefaultAuditTable when empty.
+	TableName string
+
+	mutex   sync.Mutex
+	created bool
+}
+
+// NewTableAuditSink returns a sink writing to the table, DefaultAuditTable
+// when tableName is empty.
+func NewTableAuditSink(db *sql.DB, databaseType DatabaseType, tableName string) *TableAuditSink {
+	return &TableAuditSink{DB: db, DatabaseType: databaseType, TableName: tableName}
+}
+
+var auditTableColumns = []string{
+	"occurred_at", "actor", "operation", "table_name", "primary_key", "before_values", "after_values", "details",
+}
+
+func (s *TableAuditSink) WriteAudit(entry AuditEntry) error {
+	tableName := s.TableName
+	if tableName == "" {
+		tableName = DefaultAuditTable
+	}
+
+	o := newOptions(s.DB, OpWriteAudit, tableName, nil)
+
+	quotedTableName, err := quoteIdentifier(tableName, s.DatabaseType)
+	if err != nil {
+		return fmt.Errorf("TableAuditSink - %w", err)
+	}
+
+	if err := s.createTable(o, tableName, quotedTableName); err != nil {
+		return fmt.Errorf("TableAuditSink - creating audit table: %w", err)
+	}
+
+	quotedColumns, err := quoteIdentifiers(auditTableColumns, s.DatabaseType)
+	if err != nil {
+		return fmt.Errorf("TableAuditSink - %w", err)
+	}
+	placeholders, err := getPlaceholders(s.DatabaseType, 1, len(auditTableColumns))
+	if err != nil {
+		return fmt.Errorf("TableAuditSink - %w", err)
+	}
+
+	args := []interface{}{entry.Time, entry.Actor, string(entry.Operation), entry.Table}
+	for _, value := range []interface{}{entry.PrimaryKey, entry.Before, entry.After, entry.Details} {
+		encoded, err := marshalAuditValue(value)
+		if err != nil {
+			return fmt.Errorf("TableAuditSink - encoding values: %w", err)
+		}
+		args = append(args, encoded)
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quotedTableName,
+		strings.Join(quotedColumns, ", "),
+		strings.Join(placeholders, ", "),
+	)
+
+	if _, err := execStatement(s.DB, o, query, args...); err != nil {
+		return fmt.Errorf("TableAuditSink - %w", classifyError(err))
+	}
+
+	return nil
+}
+
+func (s *TableAuditSink) createTable(o *options, tableName, quotedTableName string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.created {
+		return nil
+	}
+
+	exists, err := tableExists(s.DB, o, tableName, s.DatabaseType)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		queryTemplate, err := getQueryForAuditTable(s.DatabaseType)
+		if err != nil {
+			return err
+		}
+
+		if _, err := execStatement(s.DB, o, fmt.Sprintf(queryTemplate, quotedTableName)); err != nil {
+			// another process may have created it in the meantime
+			if exists, _ := tableExists(s.DB, o, tableName, s.DatabaseType); !exists {
+				return classifyError(err)
+			}
+		}
+	}
+
+	s.created = true
+	return nil
+}
+
+// Empty values are stored as NULL.
+func marshalAuditValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case TableRecord:
+		if v == nil {
+			return nil, nil
+		}
+	case map[string]interface{}:
+		if v == nil {
+			return nil, nil
+		}
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+// JSONLinesAuditSink writes every entry as one line of JSON.
+type JSONLinesAuditSink struct {
+	mutex  sync.Mutex
+	writer io.Writer
+	closer io.Closer
+}
+
+// NewJSONLinesAuditSink returns a sink writing to w.
+func NewJSONLinesAuditSink(w io.Writer) *JSONLinesAuditSink {
+	return &JSONLinesAuditSink{writer: w}
+}
+
+// OpenJSONLinesAuditFile returns a sink appending to the file, created when
+// missing. Close the sink to close the file.
+func OpenJSONLinesAuditFile(path string) (*JSONLinesAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("OpenJSONLinesAuditFile - %w", err)
+	}
+	return &JSONLinesAuditSink{writer: file, closer: file}, nil
+}
+
+func (s *JSONLinesAuditSink) WriteAudit(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("JSONLinesAuditSink - %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, err := s.writer.Write(line); err != nil {
+		return fmt.Errorf("JSONLinesAuditSink - %w", err)
+	}
+	return nil
+}
+
+// Close closes the file opened by OpenJSONLinesAuditFile, it does nothing for
+// sinks created with NewJSONLinesAuditSink.
+func (s *JSONLinesAuditSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
@@ -0,0 +1,649 @@
+package sqlutils
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// RowSource hands the records to BulkLoad one at a time, Next returns io.EOF
+// after the last one.
+type RowSource interface {
+	Next() ([]interface{}, error)
+}
+
+// RowSourceFunc adapts a function to RowSource.
+type RowSourceFunc func() ([]interface{}, error)
+
+func (f RowSourceFunc) Next() ([]interface{}, error) {
+	return f()
+}
+
+// SliceRows returns a RowSource over the rows.
+func SliceRows(rows [][]interface{}) RowSource {
+	index := 0
+	return RowSourceFunc(func() ([]interface{}, error) {
+		if index == len(rows) {
+			return nil, io.EOF
+		}
+		index++
+		return rows[index-1], nil
+	})
+}
+
+// Package paths of the drivers with a bulk load path.
+const (
+	pqDriverPackage     = "github.com/lib/pq"
+	mssqlDriverPackage  = "github.com/denisenkom/go-mssqldb"
+	mysqlDriverPackage  = "github.com/go-sql-driver/mysql"
+	godrorDriverPackage = "github.com/godror/godror"
+)
+
+// The most bind parameters a statement takes.
+var maxBindParameters = map[DatabaseType]int{
+	MySQL:       65535,
+	MariaDB:     65535,
+	SQLServer:   2100,
+	PostgreSQL:  65535,
+	SQLite:      32766,
+	Oracle:      65535,
+	CockroachDB: 65535,
+}
+
+// BulkLoad inserts the rows, their values in the order of the columns, in one
+// transaction with the fast path of the driver: COPY FROM STDIN with lib/pq,
+// bulk copy with go-mssqldb, LOAD DATA LOCAL INFILE with go-sql-driver/mysql
+// (the server has to allow local_infile, rows it skips or truncates fail the
+// load) and array binding with godror.
+// Other drivers, SQLite among them, get multi-row INSERT statements of
+// WithBatchSize records. Rows are read from the source as they are loaded.
+// It returns the number of rows loaded.
+func BulkLoad(
+	db *sql.DB,
+	tableName string,
+	dbType DatabaseType,
+	columns []string,
+	rows RowSource,
+	opts ...Option,
+) (int64, error) {
+	o := newOptions(db, OpBulkLoad, tableName, opts)
+
+	if err := checkGuard(o, tableName); err != nil {
+		return 0, fmt.Errorf("BulkLoad - %w", err)
+	}
+
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("BulkLoad - no columns given")
+	}
+	if _, err := validateRecordColumns(db, o, tableName, nil, dbType, columns...); err != nil {
+		return 0, fmt.Errorf("BulkLoad - %w", err)
+	}
+
+	schemas, err := getMaskedColumnSchemas(db, o, tableName, dbType)
+	if err != nil {
+		return 0, fmt.Errorf("BulkLoad - %w", err)
+	}
+
+	line := 0
+	next := func() ([]interface{}, error) {
+		row, err := rows.Next()
+		if err != nil {
+			return nil, err
+		}
+		line++
+		if len(row) != len(columns) {
+			return nil, fmt.Errorf("row %d has %d values, expected %d", line, len(row), len(columns))
+		}
+		if row, err = maskRow(o, tableName, columns, schemas, row); err != nil {
+			return nil, fmt.Errorf("row %d: %w", line, err)
+		}
+		return encodeValues(o, row)
+	}
+
+	batchSize := o.batchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	var count int64
+	switch driverPackage := getDriverPackage(db); {
+	case (dbType == PostgreSQL || dbType == CockroachDB) && driverPackage == pqDriverPackage:
+		count, err = copyIn(db, o, pq.CopyIn(tableName, columns...), next)
+	case dbType == SQLServer && driverPackage == mssqlDriverPackage:
+		var quotedTableName string
+		quotedTableName, err = quoteIdentifier(tableName, dbType)
+		if err == nil {
+			count, err = copyIn(db, o, mssql.CopyIn(quotedTableName, mssql.BulkOptions{}, columns...), next)
+		}
+	case (dbType == MySQL || dbType == MariaDB) && driverPackage == mysqlDriverPackage:
+		count, err = loadData(db, o, tableName, dbType, columns, next)
+	case dbType == Oracle && driverPackage == godrorDriverPackage:
+		count, err = insertArrays(db, o, tableName, dbType, columns, batchSize, next)
+	default:
+		count, err = insertBatches(db, o, tableName, dbType, columns, batchSize, next)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("BulkLoad - %w", classifyError(err))
+	}
+
+	if err := writeAudit(o, AuditEntry{Details: map[string]interface{}{"rows": count}}); err != nil {
+		return count, fmt.Errorf("BulkLoad - %w", err)
+	}
+
+	return count, nil
+}
+
+func getDriverPackage(db *sql.DB) string {
+	driverType := reflect.TypeOf(db.Driver())
+	for driverType.Kind() == reflect.Ptr {
+		driverType = driverType.Elem()
+	}
+	return driverType.PkgPath()
+}
+
+// Runs the copy statements of lib/pq and go-mssqldb: the statement is
+// prepared, executed once per row and once without arguments to flush.
+func copyIn(db *sql.DB, o *options, query string, next func() ([]interface{}, error)) (int64, error) {
+	if o.dryRun != nil {
+		o.dryRun.Statements = append(o.dryRun.Statements, Statement{SQL: query})
+		return 0, nil
+	}
+
+	var count int64
+	err := runWithHooks(o, query, nil, func(ctx context.Context) (int64, error) {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return -1, err
+		}
+		defer tx.Rollback()
+
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return -1, err
+		}
+		defer stmt.Close()
+
+		for {
+			row, err := next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return -1, err
+			}
+			if _, err := stmt.ExecContext(ctx, row...); err != nil {
+				return -1, err
+			}
+			count++
+		}
+
+		if _, err := stmt.ExecContext(ctx); err != nil {
+			return -1, err
+		}
+		if err := stmt.Close(); err != nil {
+			return -1, err
+		}
+
+		return count, tx.Commit()
+	})
+
+	return count, err
+}
+
+var loadDataEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`, "\x00", `\0`)
+
+// Streams the rows as tab separated lines to LOAD DATA through a reader
+// registered with the driver. Binary columns are sent in hex.
+func loadData(
+	db *sql.DB,
+	o *options,
+	tableName string,
+	dbType DatabaseType,
+	columns []string,
+	next func() ([]interface{}, error),
+) (int64, error) {
+	decodings, err := getTableDecodings(db, o, tableName, dbType)
+	if err != nil {
+		return 0, err
+	}
+
+	quotedTableName, err := quoteIdentifier(tableName, dbType)
+	if err != nil {
+		return 0, err
+	}
+
+	binary := make([]bool, len(columns))
+	targets := make([]string, len(columns))
+	var assignments []string
+	for i, column := range columns {
+		quotedColumn, err := quoteIdentifier(column, dbType)
+		if err != nil {
+			return 0, err
+		}
+		targets[i] = quotedColumn
+
+		if decodings[column].kind == kindBinary {
+			binary[i] = true
+			targets[i] = fmt.Sprintf("@c%d", i)
+			assignments = append(assignments, fmt.Sprintf("%s = UNHEX(@c%d)", quotedColumn, i))
+		}
+	}
+
+	readerName := "sqlutils_" + getRandomString(16)
+	query := fmt.Sprintf(
+		`LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE %s CHARACTER SET utf8mb4 FIELDS TERMINATED BY '\t' ESCAPED BY '\\' LINES TERMINATED BY '\n' (%s)`,
+		readerName, quotedTableName, strings.Join(targets, ", "),
+	)
+	if len(assignments) != 0 {
+		query += " SET " + strings.Join(assignments, ", ")
+	}
+
+	reader, writer := io.Pipe()
+	mysql.RegisterReaderHandler(readerName, func() io.Reader { return reader })
+	defer mysql.DeregisterReaderHandler(readerName)
+
+	// times are written in the zone of the codec, UTC otherwise like the
+	// driver does without loc in the DSN
+	location := time.UTC
+	if o.codec != nil && o.codec.Location != nil {
+		location = o.codec.Location
+	}
+
+	var sent int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var err error
+		sent, err = writeLoadDataRows(writer, binary, location, next)
+		writer.CloseWithError(err)
+	}()
+
+	var count int64
+	err = inTransaction(db, o, true, func(e execer) error {
+		result, err := execStatement(e, o, query)
+		if err != nil {
+			return err
+		}
+		if o.dryRun != nil {
+			return nil
+		}
+		count, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		reader.Close()
+		<-done
+
+		// LOCAL turns the errors of the rows into warnings, the rows being
+		// skipped or their values truncated
+		if err := checkLoadDataWarnings(e, o); err != nil {
+			return err
+		}
+		if count != sent {
+			return fmt.Errorf("LOAD DATA loaded %d of the %d rows sent", count, sent)
+		}
+		return nil
+	})
+
+	// unblocks the writer when the statement did not read everything
+	reader.Close()
+	<-done
+
+	return count, err
+}
+
+// Fails on the first warning left by LOAD DATA.
+func checkLoadDataWarnings(e execer, o *options) error {
+	rows, err := queryRows(e, o, "SHOW WARNINGS LIMIT 1")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return rows.Err()
+	}
+
+	var level, message string
+	var code int
+	if err := rows.Scan(&level, &code, &message); err != nil {
+		return err
+	}
+	return fmt.Errorf("LOAD DATA %s %d: %s", level, code, message)
+}
+
+// Returns the number of rows written.
+func writeLoadDataRows(w io.Writer, binary []bool, location *time.Location, next func() ([]interface{}, error)) (int64, error) {
+	var count int64
+	buffered := bufio.NewWriter(w)
+	for {
+		row, err := next()
+		if err == io.EOF {
+			return count, buffered.Flush()
+		}
+		if err != nil {
+			return count, err
+		}
+
+		for i, value := range row {
+			if i > 0 {
+				buffered.WriteByte('\t')
+			}
+			buffered.WriteString(formatLoadDataValue(value, binary[i], location))
+		}
+		if err := buffered.WriteByte('\n'); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+func formatLoadDataValue(value interface{}, binary bool, location *time.Location) string {
+	switch v := value.(type) {
+	case nil:
+		return `\N`
+	case []byte:
+		if binary {
+			return hex.EncodeToString(v)
+		}
+		return loadDataEscaper.Replace(string(v))
+	case string:
+		if binary {
+			return hex.EncodeToString([]byte(v))
+		}
+		return loadDataEscaper.Replace(v)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		return v.In(location).Format("2006-01-02 15:04:05.999999")
+	default:
+		return loadDataEscaper.Replace(fmt.Sprint(v))
+	}
+}
+
+// Inserts the rows in batches, binding each column of a batch as one array.
+func insertArrays(
+	db *sql.DB,
+	o *options,
+	tableName string,
+	dbType DatabaseType,
+	columns []string,
+	batchSize int,
+	next func() ([]interface{}, error),
+) (int64, error) {
+	query, err := renderInsert(tableName, dbType, columns, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	err = inTransaction(db, o, true, func(e execer) error {
+		batch := make([][]interface{}, 0, batchSize)
+		flush := func() error {
+			args := batch[0]
+			if len(batch) > 1 {
+				var err error
+				if args, err = columnArrays(batch, columns); err != nil {
+					return err
+				}
+			}
+			if _, err := execStatement(e, o, query, args...); err != nil {
+				return err
+			}
+			count += int64(len(batch))
+			batch = batch[:0]
+			return nil
+		}
+
+		for {
+			row, err := next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			batch = append(batch, row)
+			if len(batch) == batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(batch) == 0 {
+			return nil
+		}
+		return flush()
+	})
+
+	return count, err
+}
+
+// Turns the rows into one typed slice per column, typed after the first
+// value that is not nil.
+func columnArrays(rows [][]interface{}, columns []string) ([]interface{}, error) {
+	arrays := make([]interface{}, len(columns))
+
+	for i, column := range columns {
+		var first interface{}
+		for _, row := range rows {
+			if row[i] != nil {
+				first = row[i]
+				break
+			}
+		}
+
+		mismatch := func(value interface{}) error {
+			return fmt.Errorf("column %s mixes %T and %T values", column, first, value)
+		}
+
+		switch first.(type) {
+		case int, int8, int16, int32, int64, uint8, uint16, uint32, bool:
+			array := make([]sql.NullInt64, len(rows))
+			for j, row := range rows {
+				if row[i] == nil {
+					continue
+				}
+				value, ok := toInt64(row[i])
+				if !ok {
+					return nil, mismatch(row[i])
+				}
+				array[j] = sql.NullInt64{Int64: value, Valid: true}
+			}
+			arrays[i] = array
+		case float32, float64:
+			array := make([]sql.NullFloat64, len(rows))
+			for j, row := range rows {
+				switch value := row[i].(type) {
+				case nil:
+				case float32:
+					array[j] = sql.NullFloat64{Float64: float64(value), Valid: true}
+				case float64:
+					array[j] = sql.NullFloat64{Float64: value, Valid: true}
+				default:
+					return nil, mismatch(value)
+				}
+			}
+			arrays[i] = array
+		case time.Time:
+			array := make([]sql.NullTime, len(rows))
+			for j, row := range rows {
+				switch value := row[i].(type) {
+				case nil:
+				case time.Time:
+					array[j] = sql.NullTime{Time: value, Valid: true}
+				default:
+					return nil, mismatch(value)
+				}
+			}
+			arrays[i] = array
+		case []byte:
+			array := make([][]byte, len(rows))
+			for j, row := range rows {
+				switch value := row[i].(type) {
+				case nil:
+				case []byte:
+					array[j] = value
+				default:
+					return nil, mismatch(value)
+				}
+			}
+			arrays[i] = array
+		default:
+			// Oracle stores empty strings as NULL
+			array := make([]string, len(rows))
+			for j, row := range rows {
+				if row[i] != nil {
+					array[j] = fmt.Sprint(row[i])
+				}
+			}
+			arrays[i] = array
+		}
+	}
+
+	return arrays, nil
+}
+
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// Inserts the rows with multi-row INSERT statements, within the bind
+// parameter limit of the dialect.
+func insertBatches(
+	db *sql.DB,
+	o *options,
+	tableName string,
+	dbType DatabaseType,
+	columns []string,
+	batchSize int,
+	next func() ([]interface{}, error),
+) (int64, error) {
+	if limit := maxBindParameters[dbType] / len(columns); limit < batchSize {
+		batchSize = limit
+	}
+	switch dbType {
+	case SQLServer:
+		// table value constructors take up to 1000 rows
+		if batchSize > 1000 {
+			batchSize = 1000
+		}
+	case Oracle:
+		// no multi-row VALUES
+		batchSize = 1
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	var count int64
+	err := inTransaction(db, o, true, func(e execer) error {
+		batch := make([]interface{}, 0, batchSize*len(columns))
+		rowCount := 0
+		flush := func() error {
+			query, err := renderInsert(tableName, dbType, columns, rowCount)
+			if err != nil {
+				return err
+			}
+			if _, err := execStatement(e, o, query, batch...); err != nil {
+				return err
+			}
+			count += int64(rowCount)
+			batch = batch[:0]
+			rowCount = 0
+			return nil
+		}
+
+		for {
+			row, err := next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			batch = append(batch, row...)
+			rowCount++
+			if rowCount == batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+
+		if rowCount == 0 {
+			return nil
+		}
+		return flush()
+	})
+
+	return count, err
+}
+
+// Renders an INSERT statement of the columns for the number of rows.
+func renderInsert(tableName string, dbType DatabaseType, columns []string, rowCount int) (string, error) {
+	quotedTableName, err := quoteIdentifier(tableName, dbType)
+	if err != nil {
+		return "", err
+	}
+	quotedColumns, err := quoteIdentifiers(columns, dbType)
+	if err != nil {
+		return "", err
+	}
+	placeholders, err := getPlaceholders(dbType, 1, rowCount*len(columns))
+	if err != nil {
+		return "", err
+	}
+
+	values := make([]string, rowCount)
+	for i := range values {
+		values[i] = "(" + strings.Join(placeholders[i*len(columns):(i+1)*len(columns)], ", ") + ")"
+	}
+
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
+		quotedTableName,
+		strings.Join(quotedColumns, ", "),
+		strings.Join(values, ", "),
+	), nil
+}
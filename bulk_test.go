@@ -0,0 +1,84 @@
+package sqlutils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBulkLoadInsertBatches(t *testing.T) {
+	db := openTestDB(t, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, price REAL)")
+
+	rows := make([][]interface{}, 1200)
+	for index := range rows {
+		rows[index] = []interface{}{index + 1, fmt.Sprint("item ", index+1), float64(index) / 4}
+	}
+
+	recorder := &statementRecorder{}
+	count, err := BulkLoad(db, "items", SQLite, []string{"id", "name", "price"}, SliceRows(rows),
+		WithBatchSize(500), WithHooks(recorder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1200 {
+		t.Fatalf("BulkLoad loaded %d rows, want 1200", count)
+	}
+	if got := countRows(t, db, "items"); got != 1200 {
+		t.Fatalf("table has %d rows, want 1200", got)
+	}
+	if inserts := recorder.count("INSERT"); inserts != 3 {
+		t.Fatalf("BulkLoad ran %d INSERT statements, want 3 batches of 500 rows at most", inserts)
+	}
+
+	var name string
+	var price float64
+	if err := db.QueryRow("SELECT name, price FROM items WHERE id = 1001").Scan(&name, &price); err != nil {
+		t.Fatal(err)
+	}
+	if name != "item 1001" || price != 250 {
+		t.Fatalf("row 1001 is (%q, %v), want (\"item 1001\", 250)", name, price)
+	}
+}
+
+func TestBulkLoadSplitsBatchesAtBindParameterLimit(t *testing.T) {
+	columns := make([]string, 40)
+	definitions := make([]string, len(columns))
+	for index := range columns {
+		columns[index] = fmt.Sprint("c", index)
+		definitions[index] = columns[index] + " INTEGER"
+	}
+	db := openTestDB(t, "CREATE TABLE wide ("+strings.Join(definitions, ", ")+")")
+
+	rows := make([][]interface{}, 1000)
+	for index := range rows {
+		rows[index] = make([]interface{}, len(columns))
+		for column := range columns {
+			rows[index][column] = index*len(columns) + column
+		}
+	}
+
+	// 40 columns leave room for 819 rows within the 32766 parameters of SQLite
+	recorder := &statementRecorder{}
+	count, err := BulkLoad(db, "wide", SQLite, columns, SliceRows(rows), WithBatchSize(1000), WithHooks(recorder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1000 || countRows(t, db, "wide") != 1000 {
+		t.Fatalf("BulkLoad loaded %d rows, want 1000", count)
+	}
+	if inserts := recorder.count("INSERT"); inserts != 2 {
+		t.Fatalf("BulkLoad ran %d INSERT statements, want 2", inserts)
+	}
+}
+
+func TestBulkLoadRejectsShortRows(t *testing.T) {
+	db := openTestDB(t, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)")
+
+	rows := [][]interface{}{{1, "a"}, {2}}
+	if _, err := BulkLoad(db, "items", SQLite, []string{"id", "name"}, SliceRows(rows)); err == nil {
+		t.Fatal("BulkLoad accepted a row missing a value")
+	}
+	if got := countRows(t, db, "items"); got != 0 {
+		t.Fatalf("table has %d rows after the failed load, want 0", got)
+	}
+}
@@ -0,0 +1,212 @@
+package sqlutils
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValueCodec controls how GetTable decodes the values of the columns and how
+// InsertRecord, EditRecord and RemoveRecord encode the values they are given.
+type ValueCodec struct {
+	// Location converts the times read and written, they are left in the zone
+	// the driver uses when nil.
+	Location *time.Location
+	// TimeFormat is the layout times are formatted with when read, they are
+	// returned as time.Time when empty.
+	TimeFormat string
+	// DecimalAsString returns decimals as their exact text, as float64
+	// otherwise.
+	DecimalAsString bool
+	// ParseJSON decodes JSON columns into maps, slices and scalars, with the
+	// numbers as json.Number, they are returned as text otherwise. Maps,
+	// slices and structs written are always encoded as JSON.
+	ParseJSON bool
+	// CanonicalUUID returns UUID columns as lower case 8-4-4-4-12 strings,
+	// including the binary UNIQUEIDENTIFIER values of SQL Server, which are
+	// returned as []byte otherwise.
+	CanonicalUUID bool
+	// Decoders replace the decoding of the columns of a database type, keyed
+	// by the upper case type name without size (VARCHAR, NUMERIC...). They are
+	// not called for NULL values.
+	Decoders map[string]func(value interface{}) (interface{}, error)
+}
+
+// The decoding used without WithValueCodec.
+var defaultValueCodec = &ValueCodec{
+	TimeFormat:      time.RFC3339,
+	DecimalAsString: true,
+	ParseJSON:       true,
+}
+
+// WithValueCodec decodes and encodes the values of the call with the codec.
+// Without it times are read as RFC3339 strings, decimals as strings, JSON
+// parsed, UUIDs as the driver returns them, and times written unchanged.
+func WithValueCodec(codec *ValueCodec) Option {
+	return func(o *options) {
+		o.codec = codec
+	}
+}
+
+func getValueCodec(o *options) *ValueCodec {
+	if o.codec != nil {
+		return o.codec
+	}
+	return defaultValueCodec
+}
+
+func (c *ValueCodec) decodeTime(t time.Time) interface{} {
+	if c.Location != nil {
+		t = t.In(c.Location)
+	}
+	if c.TimeFormat == "" {
+		return t
+	}
+	return t.Format(c.TimeFormat)
+}
+
+func (c *ValueCodec) decodeDecimal(value interface{}) (interface{}, error) {
+	var text string
+	switch v := value.(type) {
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	case fmt.Stringer:
+		text = v.String()
+	default:
+		// drivers such as SQLite already hand over numbers
+		return value, nil
+	}
+
+	if c.DecimalAsString {
+		return text, nil
+	}
+
+	f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return nil, fmt.Errorf("decoding decimal %q: %w", text, err)
+	}
+	return f, nil
+}
+
+func (c *ValueCodec) decodeJSON(value interface{}) (interface{}, error) {
+	text, ok := textValue(value)
+	if !ok {
+		return value, nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("decoding JSON: %w", err)
+	}
+	return decoded, nil
+}
+
+// SQL Server stores the first three groups of a UUID little endian.
+func decodeUUID(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case []byte:
+		if len(v) == 16 {
+			b := append([]byte(nil), v...)
+			b[0], b[1], b[2], b[3] = b[3], b[2], b[1], b[0]
+			b[4], b[5] = b[5], b[4]
+			b[6], b[7] = b[7], b[6]
+			return formatUUID(b), nil
+		}
+		return canonicalUUID(string(v))
+	case string:
+		return canonicalUUID(v)
+	case [16]byte:
+		return formatUUID(v[:]), nil
+	default:
+		return value, nil
+	}
+}
+
+func canonicalUUID(text string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		if r == '-' || r == '{' || r == '}' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(text))
+
+	b, err := hex.DecodeString(digits)
+	if err != nil || len(b) != 16 {
+		return "", fmt.Errorf("decoding UUID %q: invalid format", text)
+	}
+	return formatUUID(b), nil
+}
+
+func formatUUID(b []byte) string {
+	encoded := hex.EncodeToString(b)
+	return encoded[0:8] + "-" + encoded[8:12] + "-" + encoded[12:16] + "-" + encoded[16:20] + "-" + encoded[20:32]
+}
+
+// Encodes the values written by the call: maps, slices and structs as JSON
+// text, which the drivers do not take otherwise, and times with the codec
+// given by WithValueCodec.
+func encodeValues(o *options, values []interface{}) ([]interface{}, error) {
+	encoded := make([]interface{}, len(values))
+	for index, value := range values {
+		var err error
+		encoded[index], err = encodeValue(o.codec, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return encoded, nil
+}
+
+func encodeValue(codec *ValueCodec, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, []byte:
+		return value, nil
+	case json.RawMessage:
+		return string(v), nil
+	case time.Time:
+		if codec != nil && codec.Location != nil {
+			return v.In(codec.Location), nil
+		}
+		return v, nil
+	case *time.Time:
+		if v != nil && codec != nil && codec.Location != nil {
+			return v.In(codec.Location), nil
+		}
+		return value, nil
+	}
+
+	reflectValue := reflect.Indirect(reflect.ValueOf(value))
+	switch reflectValue.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		if reflectValue.Kind() != reflect.Map && reflectValue.Kind() != reflect.Struct &&
+			reflectValue.Type().Elem().Kind() == reflect.Uint8 {
+			// byte arrays such as UUIDs
+			return value, nil
+		}
+		if _, ok := value.(driver.Valuer); ok {
+			// such as sql.NullString, which encode themselves
+			return value, nil
+		}
+
+		var b bytes.Buffer
+		encoder := json.NewEncoder(&b)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(value); err != nil {
+			return nil, fmt.Errorf("encoding JSON: %w", err)
+		}
+		return strings.TrimSuffix(b.String(), "\n"), nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,118 @@
+package sqlutils
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Caches of a connection, keyed by table name. CloseDB drops them along with
+// the connection.
+type tableCaches struct {
+	// columns of the tables seen so far, used to validate the keys of records
+	columns sync.Map
+	// JSON columns, for the dialects that store JSON in text columns
+	jsonColumns sync.Map
+	// TINYINT(1) columns of MySQL and MariaDB
+	boolColumns sync.Map
+}
+
+var connectionCaches sync.Map
+
+func getTableCaches(db *sql.DB) *tableCaches {
+	caches, _ := connectionCaches.LoadOrStore(db, &tableCaches{})
+	return caches.(*tableCaches)
+}
+
+// UnknownColumnsError is returned when a record references columns the table
+// does not have.
+type UnknownColumnsError struct {
+	Table   string
+	Columns []string
+}
+
+func (e *UnknownColumnsError) Error() string {
+	return fmt.Sprintf("unknown columns for table %s: %s", e.Table, strings.Join(e.Columns, ", "))
+}
+
+// ResetColumnCache forgets the cached columns of the given tables, or of every
+// table of the connection when none are given. Call it after altering tables
+// outside of this package.
+func ResetColumnCache(db *sql.DB, tableNames ...string) {
+	if len(tableNames) == 0 {
+		connectionCaches.Delete(db)
+		return
+	}
+
+	caches, ok := connectionCaches.Load(db)
+	if !ok {
+		return
+	}
+	for _, tableName := range tableNames {
+		caches.(*tableCaches).columns.Delete(tableName)
+		caches.(*tableCaches).jsonColumns.Delete(tableName)
+		caches.(*tableCaches).boolColumns.Delete(tableName)
+	}
+}
+
+func getCachedColumns(db *sql.DB, o *options, tableName string, databaseType DatabaseType) (map[string]bool, error) {
+	cache := &getTableCaches(db).columns
+	if columns, ok := cache.Load(tableName); ok {
+		return columns.(map[string]bool), nil
+	}
+
+	columnNames, err := GetColumns(db, tableName, databaseType, inherit(o))
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]bool, len(columnNames))
+	for _, columnName := range columnNames {
+		columns[columnName] = true
+	}
+	cache.Store(tableName, columns)
+
+	return columns, nil
+}
+
+// Checks the record keys and the extra column names against the columns of the
+// table. With WithLenientColumns unknown record keys are dropped from the
+// returned copy instead of failing, unknown extra columns are always an error.
+func validateRecordColumns(
+	db *sql.DB,
+	o *options,
+	tableName string,
+	record TableRecord,
+	databaseType DatabaseType,
+	extraColumns ...string,
+) (TableRecord, error) {
+	columns, err := getCachedColumns(db, o, tableName, databaseType)
+	if err != nil {
+		return nil, err
+	}
+
+	var unknown []string
+	for _, column := range extraColumns {
+		if !columns[column] {
+			unknown = append(unknown, column)
+		}
+	}
+
+	validated := make(TableRecord, len(record))
+	for key, value := range record {
+		if columns[key] {
+			validated[key] = value
+		} else if !o.lenientColumns {
+			unknown = append(unknown, key)
+		}
+	}
+
+	if len(unknown) != 0 {
+		sort.Strings(unknown)
+		return nil, &UnknownColumnsError{Table: tableName, Columns: unknown}
+	}
+
+	return validated, nil
+}
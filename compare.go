@@ -0,0 +1,883 @@
+package sqlutils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultChunkSize = 1000
+
+// The decoding values are compared with: times as time.Time, decimals as
+// their exact text, JSON parsed and UUIDs canonical, whatever the dialect.
+var compareValueCodec = &ValueCodec{
+	DecimalAsString: true,
+	ParseJSON:       true,
+	CanonicalUUID:   true,
+}
+
+// WithChunkSize sets how many rows CompareTables, TableChecksum and SyncTable
+// handle per primary key range, 1000 by default.
+func WithChunkSize(size int) Option {
+	return func(o *options) {
+		o.chunkSize = size
+	}
+}
+
+// ColumnDiff is a column whose value differs between the two tables.
+type ColumnDiff struct {
+	Column string      `json:"column"`
+	A      interface{} `json:"a"`
+	B      interface{} `json:"b"`
+}
+
+// RowDiff is a row found in both tables with different values.
+type RowDiff struct {
+	Key     TableRecord  `json:"key"`
+	Columns []ColumnDiff `json:"columns"`
+}
+
+// TableDiff is the outcome of CompareTables.
+type TableDiff struct {
+	// Columns lists the columns compared.
+	Columns []string `json:"columns"`
+	// Added holds the rows only found in table B, Removed the rows only
+	// found in table A.
+	Added     []TableRecord `json:"added"`
+	Removed   []TableRecord `json:"removed"`
+	Changed   []RowDiff     `json:"changed"`
+	Unchanged int64         `json:"unchanged"`
+}
+
+// Equal reports whether the tables hold the same rows.
+func (d TableDiff) Equal() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// CompareTables matches the rows of table A and table B by the primary key
+// of table A and reports the rows added in B, removed from A and changed,
+// column by column. The tables may live in different databases and dialects:
+// numbers are compared by value, times as instants and JSON parsed.
+// WithColumns limits the columns compared, the columns of A also found in B
+// are compared otherwise. WithFilters applies to both tables.
+//
+// Both tables are read in primary key order a chunk at a time, which fails
+// with ErrKeyOrder when a database sorts the keys differently, as text keys
+// with a case insensitive collation do.
+func CompareTables(
+	dbA *sql.DB,
+	tableA string,
+	dbTypeA DatabaseType,
+	dbB *sql.DB,
+	tableB string,
+	dbTypeB DatabaseType,
+	opts ...Option,
+) (TableDiff, error) {
+	oA := newOptions(dbA, OpCompareTables, tableA, opts)
+	oB := newOptions(dbB, OpCompareTables, tableB, opts)
+
+	if err := checkGuard(oA, tableA); err != nil {
+		return TableDiff{}, fmt.Errorf("CompareTables - %w", err)
+	}
+	if err := checkGuard(oB, tableB); err != nil {
+		return TableDiff{}, fmt.Errorf("CompareTables - %w", err)
+	}
+
+	a, b, err := openTablePair(dbA, oA, tableA, dbTypeA, dbB, oB, tableB, dbTypeB)
+	if err != nil {
+		return TableDiff{}, fmt.Errorf("CompareTables - %w", err)
+	}
+
+	diff := TableDiff{Columns: a.columns}
+	err = mergeTables(a, b, func(rowA, rowB []interface{}) error {
+		switch {
+		case rowB == nil:
+			diff.Removed = append(diff.Removed, a.record(rowA))
+		case rowA == nil:
+			diff.Added = append(diff.Added, b.record(rowB))
+		default:
+			columns := diffRow(a.columns, rowA, rowB)
+			if len(columns) == 0 {
+				diff.Unchanged++
+				break
+			}
+			diff.Changed = append(diff.Changed, RowDiff{Key: a.keyRecord(rowA), Columns: columns})
+		}
+		return nil
+	})
+	if err != nil {
+		return diff, fmt.Errorf("CompareTables - %w", err)
+	}
+
+	return diff, nil
+}
+
+// Opens readers over the columns the two tables are compared on, keyed by
+// the primary key of table A.
+func openTablePair(
+	dbA *sql.DB,
+	oA *options,
+	tableA string,
+	dbTypeA DatabaseType,
+	dbB *sql.DB,
+	oB *options,
+	tableB string,
+	dbTypeB DatabaseType,
+) (*keysetReader, *keysetReader, error) {
+	primaryKeys, err := GetPrimaryKeys(dbA, "", tableA, dbTypeA, inherit(oA))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(primaryKeys) == 0 {
+		return nil, nil, fmt.Errorf("table %s has no primary key", tableA)
+	}
+
+	var columns []string
+	if len(oA.columns) != 0 {
+		columns = withPrimaryKeys(oA.columns, primaryKeys)
+		if _, err := validateRecordColumns(dbA, oA, tableA, nil, dbTypeA, columns...); err != nil {
+			return nil, nil, err
+		}
+		if _, err := validateRecordColumns(dbB, oB, tableB, nil, dbTypeB, columns...); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		columnsA, err := GetColumns(dbA, tableA, dbTypeA, inherit(oA))
+		if err != nil {
+			return nil, nil, err
+		}
+		columnsB, err := GetColumns(dbB, tableB, dbTypeB, inherit(oB))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		inB := make(map[string]bool, len(columnsB))
+		for _, column := range columnsB {
+			inB[column] = true
+		}
+		for _, column := range columnsA {
+			if inB[column] {
+				columns = append(columns, column)
+			}
+		}
+
+		var missing []string
+		for _, key := range primaryKeys {
+			if !inB[key] {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) != 0 {
+			return nil, nil, &UnknownColumnsError{Table: tableB, Columns: missing}
+		}
+	}
+
+	a, err := newKeysetReader(dbA, oA, tableA, dbTypeA, columns, primaryKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := newKeysetReader(dbB, oB, tableB, dbTypeB, columns, primaryKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return a, b, nil
+}
+
+// Puts the primary keys missing from the columns in front of them.
+func withPrimaryKeys(columns, primaryKeys []string) []string {
+	present := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		present[column] = true
+	}
+
+	var all []string
+	for _, key := range primaryKeys {
+		if !present[key] {
+			all = append(all, key)
+		}
+	}
+	return append(all, columns...)
+}
+
+// Walks the rows of both readers in primary key order, handing the rows
+// with the same key together to visit, and nil for the side a key is
+// missing from.
+func mergeTables(a, b *keysetReader, visit func(rowA, rowB []interface{}) error) error {
+	rowA, err := a.next()
+	if err != nil {
+		return err
+	}
+	rowB, err := b.next()
+	if err != nil {
+		return err
+	}
+
+	for rowA != nil || rowB != nil {
+		order := 0
+		switch {
+		case rowB == nil:
+			order = -1
+		case rowA == nil:
+			order = 1
+		default:
+			order = compareKeys(a.key(rowA), b.key(rowB))
+		}
+
+		switch {
+		case order < 0:
+			err = visit(rowA, nil)
+		case order > 0:
+			err = visit(nil, rowB)
+		default:
+			err = visit(rowA, rowB)
+		}
+		if err != nil {
+			return err
+		}
+
+		if order <= 0 {
+			if rowA, err = a.next(); err != nil {
+				return err
+			}
+		}
+		if order >= 0 {
+			if rowB, err = b.next(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func diffRow(columns []string, rowA, rowB []interface{}) []ColumnDiff {
+	var diffs []ColumnDiff
+	for i, column := range columns {
+		if !valuesEqual(rowA[i], rowB[i]) {
+			diffs = append(diffs, ColumnDiff{Column: column, A: rowA[i], B: rowB[i]})
+		}
+	}
+	return diffs
+}
+
+// Reads the rows of a table in primary key order a chunk at a time, each
+// chunk starting after the key of the last row read, so that no query
+// stays open between chunks.
+type keysetReader struct {
+	db          *sql.DB
+	o           *options
+	tableName   string
+	dbType      DatabaseType
+	columns     []string
+	primaryKeys []string
+	textKeys    []bool
+	keyIndexes  []int
+	query       string
+	args        []interface{}
+	orderBy     []string
+	chunkSize   int
+	jsonColumns map[string]bool
+	boolColumns map[string]bool
+
+	chunk    [][]interface{}
+	position int
+	done     bool
+	// the key of the last row read, as scanned to be bound again and decoded
+	// to be compared
+	lastRaw []interface{}
+	lastKey []interface{}
+}
+
+func newKeysetReader(
+	db *sql.DB,
+	o *options,
+	tableName string,
+	dbType DatabaseType,
+	columns []string,
+	primaryKeys []string,
+) (*keysetReader, error) {
+	quotedColumns, err := quoteIdentifiers(columns, dbType)
+	if err != nil {
+		return nil, err
+	}
+	query, err := getQueryForSelectColumns(tableName, quotedColumns, dbType)
+	if err != nil {
+		return nil, fmt.Errorf("grabbing db type specific query: %w", err)
+	}
+	query, args, err := applyFilters(query, o, dbType)
+	if err != nil {
+		return nil, err
+	}
+	orderBy, err := quoteIdentifiers(primaryKeys, dbType)
+	if err != nil {
+		return nil, err
+	}
+
+	// text keys are sorted in binary order, the order they are compared in
+	decodings, err := getTableDecodings(db, o, tableName, dbType)
+	if err != nil {
+		return nil, fmt.Errorf("grabbing column types: %w", err)
+	}
+	textKeys := make([]bool, len(primaryKeys))
+	for i, key := range primaryKeys {
+		if decodings[key].kind == kindText {
+			textKeys[i] = true
+			orderBy[i] = collateBinary(orderBy[i], dbType)
+		}
+	}
+
+	keyIndexes := make([]int, len(primaryKeys))
+	for i, key := range primaryKeys {
+		keyIndexes[i] = -1
+		for j, column := range columns {
+			if column == key {
+				keyIndexes[i] = j
+			}
+		}
+		if keyIndexes[i] < 0 {
+			return nil, fmt.Errorf("primary key %s is not among the columns read", key)
+		}
+	}
+
+	jsonColumns, err := getJSONColumns(db, o, tableName, dbType)
+	if err != nil {
+		return nil, fmt.Errorf("grabbing JSON columns: %w", err)
+	}
+
+	boolColumns, err := getBoolColumns(db, o, tableName, dbType)
+	if err != nil {
+		return nil, fmt.Errorf("grabbing boolean columns: %w", err)
+	}
+
+	chunkSize := o.chunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	return &keysetReader{
+		db:          db,
+		o:           o,
+		tableName:   tableName,
+		dbType:      dbType,
+		columns:     columns,
+		primaryKeys: primaryKeys,
+		textKeys:    textKeys,
+		keyIndexes:  keyIndexes,
+		query:       query,
+		args:        args,
+		orderBy:     orderBy,
+		chunkSize:   chunkSize,
+		jsonColumns: jsonColumns,
+		boolColumns: boolColumns,
+	}, nil
+}
+
+// Returns the next row, nil after the last one.
+func (r *keysetReader) next() ([]interface{}, error) {
+	if r.position == len(r.chunk) {
+		if _, err := r.nextChunk(); err != nil {
+			return nil, err
+		}
+		if len(r.chunk) == 0 {
+			return nil, nil
+		}
+	}
+
+	row := r.chunk[r.position]
+	r.position++
+	return row, nil
+}
+
+// Reads the rows following the last row read, up to the chunk size. It
+// returns no rows after the last one.
+func (r *keysetReader) nextChunk() ([][]interface{}, error) {
+	r.chunk, r.position = nil, 0
+	if r.done {
+		return nil, nil
+	}
+
+	query, args := r.query, r.args
+	if r.lastRaw != nil {
+		condition, keyArgs, err := computeKeysetCondition(r.primaryKeys, r.textKeys, ">", false, r.lastRaw, r.dbType, len(args)+1)
+		if err != nil {
+			return nil, err
+		}
+		query = appendCondition(query, len(r.o.filters) != 0, condition)
+		args = append(append([]interface{}{}, args...), keyArgs...)
+	}
+
+	query, err := getQueryForPage(query, r.orderBy, r.chunkSize, r.dbType)
+	if err != nil {
+		return nil, fmt.Errorf("grabbing db type specific query: %w", err)
+	}
+
+	rows, err := queryRows(r.db, r.o, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("table %s: %w", r.tableName, classifyError(err))
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("retrieving column types: %w", err)
+	}
+	decodings := getColumnDecodings(columnTypes)
+	for i, column := range r.columns {
+		if r.jsonColumns[column] {
+			decodings[i].kind = kindJSON
+		}
+		if r.boolColumns[column] {
+			decodings[i].kind = kindBool
+		}
+	}
+
+	for rows.Next() {
+		values := make([]interface{}, len(r.columns))
+		valuePtrs := make([]interface{}, len(r.columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+
+		row := make([]interface{}, len(r.columns))
+		for i, column := range r.columns {
+			row[i], err = decodeColumnValue(values[i], decodings[i], compareValueCodec, r.o)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", column, err)
+			}
+		}
+
+		key := r.key(row)
+		if r.lastKey != nil && compareKeys(r.lastKey, key) >= 0 {
+			return nil, fmt.Errorf("table %s: %w", r.tableName, ErrKeyOrder)
+		}
+		r.lastKey = key
+		r.lastRaw = r.key(values)
+
+		r.chunk = append(r.chunk, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+
+	r.done = len(r.chunk) < r.chunkSize
+	return r.chunk, nil
+}
+
+func (r *keysetReader) key(row []interface{}) []interface{} {
+	key := make([]interface{}, len(r.keyIndexes))
+	for i, index := range r.keyIndexes {
+		key[i] = row[index]
+	}
+	return key
+}
+
+func (r *keysetReader) keyRecord(row []interface{}) TableRecord {
+	record := make(TableRecord, len(r.primaryKeys))
+	for i, key := range r.primaryKeys {
+		record[key] = row[r.keyIndexes[i]]
+	}
+	return record
+}
+
+func (r *keysetReader) record(row []interface{}) TableRecord {
+	record := make(TableRecord, len(r.columns))
+	for i, column := range r.columns {
+		record[column] = row[i]
+	}
+	return record
+}
+
+// Appends the condition to the query, which has a WHERE clause already or
+// not.
+func appendCondition(query string, hasWhere bool, condition string) string {
+	if hasWhere {
+		return query + " AND " + condition
+	}
+	return query + " WHERE " + condition
+}
+
+// Example return for the keys (a, b) after the values (1, 2):
+// ("a" > ? OR ("a" = ? AND "b" > ?)) with the arguments 1, 1, 2.
+// With inclusive the row of the values itself matches too. Text keys are
+// compared in binary order.
+func computeKeysetCondition(
+	keys []string,
+	textKeys []bool,
+	operator string,
+	inclusive bool,
+	values []interface{},
+	databaseType DatabaseType,
+	position int,
+) (string, []interface{}, error) {
+	quotedKeys, err := quoteIdentifiers(keys, databaseType)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var terms []string
+	var args []interface{}
+	addTerm := func(last int, lastOperator string) error {
+		comparisons := make([]string, last+1)
+		for i := 0; i <= last; i++ {
+			placeholder, err := getPlaceholder(databaseType, position+len(args))
+			if err != nil {
+				return err
+			}
+
+			comparisonOperator := "="
+			if i == last {
+				comparisonOperator = lastOperator
+			}
+			column := quotedKeys[i]
+			if textKeys[i] {
+				column = collateBinary(column, databaseType)
+				placeholder = collateBinary(placeholder, databaseType)
+			}
+			comparisons[i] = fmt.Sprintf("%s %s %s", column, comparisonOperator, placeholder)
+			args = append(args, values[i])
+		}
+
+		term := strings.Join(comparisons, " AND ")
+		if len(comparisons) > 1 {
+			term = "(" + term + ")"
+		}
+		terms = append(terms, term)
+		return nil
+	}
+
+	for i := range keys {
+		if err := addTerm(i, operator); err != nil {
+			return "", nil, err
+		}
+	}
+	if inclusive {
+		if err := addTerm(len(keys)-1, "="); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return "(" + strings.Join(terms, " OR ") + ")", args, nil
+}
+
+func compareKeys(a, b []interface{}) int {
+	for i := range a {
+		if order := compareValues(a[i], b[i]); order != 0 {
+			return order
+		}
+	}
+	return 0
+}
+
+// Orders two decoded values whatever types the drivers returned them as:
+// numbers by value, times as instants and text and binary by bytes. NULL
+// comes first.
+func compareValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	if order, ok := compareNumbers(a, b); ok {
+		return order
+	}
+
+	timeA, isTimeA := a.(time.Time)
+	timeB, isTimeB := b.(time.Time)
+	if isTimeA || isTimeB {
+		if !isTimeA {
+			timeA, isTimeA = parseCompareTime(a)
+		}
+		if !isTimeB {
+			timeB, isTimeB = parseCompareTime(b)
+		}
+		if isTimeA && isTimeB {
+			switch {
+			case timeA.Before(timeB):
+				return -1
+			case timeA.After(timeB):
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	bytesA, okA := bytesValue(a)
+	bytesB, okB := bytesValue(b)
+	if okA && okB {
+		return bytes.Compare(bytesA, bytesB)
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// Compares JSON documents parsed, other values with compareValues.
+func valuesEqual(a, b interface{}) bool {
+	switch a.(type) {
+	case map[string]interface{}, []interface{}:
+		return reflect.DeepEqual(a, b)
+	}
+	switch b.(type) {
+	case map[string]interface{}, []interface{}:
+		return reflect.DeepEqual(a, b)
+	}
+	return compareValues(a, b) == 0
+}
+
+// Compares the values as numbers when one of them is a number or a boolean,
+// the other one being a number or its text. Floats are compared as float64 so
+// that a float column matches a decimal one.
+func compareNumbers(a, b interface{}) (int, bool) {
+	textA, floatA, numberA := numberText(a)
+	textB, floatB, numberB := numberText(b)
+	if !numberA && !numberB {
+		return 0, false
+	}
+
+	var ok bool
+	if !numberA {
+		if textA, ok = textValue(a); !ok {
+			return 0, false
+		}
+	}
+	if !numberB {
+		if textB, ok = textValue(b); !ok {
+			return 0, false
+		}
+	}
+	textA, textB = strings.TrimSpace(textA), strings.TrimSpace(textB)
+
+	if floatA || floatB {
+		x, err := strconv.ParseFloat(textA, 64)
+		if err != nil {
+			return 0, false
+		}
+		y, err := strconv.ParseFloat(textB, 64)
+		if err != nil {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	x, ok := new(big.Rat).SetString(textA)
+	if !ok {
+		return 0, false
+	}
+	y, ok := new(big.Rat).SetString(textB)
+	if !ok {
+		return 0, false
+	}
+	return x.Cmp(y), true
+}
+
+func numberText(value interface{}) (text string, isFloat bool, isNumber bool) {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), false, true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), true, true
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true, true
+	case json.Number:
+		return string(v), false, true
+	case bool:
+		if v {
+			return "1", false, true
+		}
+		return "0", false, true
+	default:
+		return "", false, false
+	}
+}
+
+func parseCompareTime(value interface{}) (time.Time, bool) {
+	text, ok := textValue(value)
+	if !ok {
+		return time.Time{}, false
+	}
+	return parseImportTime(text, compareValueCodec)
+}
+
+func bytesValue(value interface{}) ([]byte, bool) {
+	switch v := value.(type) {
+	case []byte:
+		return v, true
+	case string:
+		return []byte(v), true
+	default:
+		return nil, false
+	}
+}
+
+// ChunkChecksum is the checksum of the rows of one primary key range.
+type ChunkChecksum struct {
+	// First and Last are the primary keys of the first and last rows.
+	First TableRecord `json:"first"`
+	Last  TableRecord `json:"last"`
+	Rows  int64       `json:"rows"`
+	Sum   string      `json:"sum"`
+}
+
+// Checksum is the outcome of TableChecksum.
+type Checksum struct {
+	Rows   int64           `json:"rows"`
+	Sum    string          `json:"sum"`
+	Chunks []ChunkChecksum `json:"chunks"`
+}
+
+// TableChecksum hashes the rows of the table in primary key order, in chunks
+// of WithChunkSize rows. Where the database has hash functions only the
+// primary keys are read and each chunk is hashed by the database, SQLite rows
+// are read and hashed here. Sums are comparable between tables of the same
+// dialect: compare the chunks to narrow down where two tables differ, then
+// CompareTables with WithFilters for the rows. WithColumns limits the
+// columns hashed and WithFilters the rows.
+func TableChecksum(db *sql.DB, tableName string, dbType DatabaseType, opts ...Option) (Checksum, error) {
+	o := newOptions(db, OpTableChecksum, tableName, opts)
+
+	if err := checkGuard(o, tableName); err != nil {
+		return Checksum{}, fmt.Errorf("TableChecksum - %w", err)
+	}
+
+	primaryKeys, err := GetPrimaryKeys(db, "", tableName, dbType, inherit(o))
+	if err != nil {
+		return Checksum{}, fmt.Errorf("TableChecksum - %w", err)
+	}
+	if len(primaryKeys) == 0 {
+		return Checksum{}, fmt.Errorf("TableChecksum - table %s has no primary key", tableName)
+	}
+
+	columns := o.columns
+	if len(columns) != 0 {
+		if _, err := validateRecordColumns(db, o, tableName, nil, dbType, columns...); err != nil {
+			return Checksum{}, fmt.Errorf("TableChecksum - %w", err)
+		}
+	} else if columns, err = GetColumns(db, tableName, dbType, inherit(o)); err != nil {
+		return Checksum{}, fmt.Errorf("TableChecksum - %w", err)
+	}
+
+	_, hashedByDatabase, err := getQueryForChecksum(tableName, columns, nil, dbType)
+	if err != nil {
+		return Checksum{}, fmt.Errorf("TableChecksum - %w", err)
+	}
+
+	readColumns := primaryKeys
+	if !hashedByDatabase {
+		readColumns = withPrimaryKeys(columns, primaryKeys)
+	}
+	reader, err := newKeysetReader(db, o, tableName, dbType, readColumns, primaryKeys)
+	if err != nil {
+		return Checksum{}, fmt.Errorf("TableChecksum - %w", err)
+	}
+
+	query, _, err := getQueryForChecksum(tableName, columns, reader.orderBy, dbType)
+	if err != nil {
+		return Checksum{}, fmt.Errorf("TableChecksum - %w", err)
+	}
+
+	var checksum Checksum
+	total := sha256.New()
+	for {
+		previous := reader.lastRaw
+		chunk, err := reader.nextChunk()
+		if err != nil {
+			return checksum, fmt.Errorf("TableChecksum - %w", err)
+		}
+		if len(chunk) == 0 {
+			break
+		}
+
+		chunkChecksum := ChunkChecksum{
+			First: reader.keyRecord(chunk[0]),
+			Last:  reader.keyRecord(chunk[len(chunk)-1]),
+			Rows:  int64(len(chunk)),
+		}
+		if hashedByDatabase {
+			chunkChecksum.Rows, chunkChecksum.Sum, err = checksumChunk(reader, query, previous)
+		} else {
+			chunkChecksum.Sum, err = hashRows(chunk)
+		}
+		if err != nil {
+			return checksum, fmt.Errorf("TableChecksum - %w", err)
+		}
+
+		fmt.Fprintf(total, "%d:%s\n", chunkChecksum.Rows, chunkChecksum.Sum)
+		checksum.Rows += chunkChecksum.Rows
+		checksum.Chunks = append(checksum.Chunks, chunkChecksum)
+	}
+	checksum.Sum = hex.EncodeToString(total.Sum(nil))
+
+	return checksum, nil
+}
+
+// Has the database count and hash the rows after the previous chunk up to
+// the last row the reader read.
+func checksumChunk(reader *keysetReader, query string, previous []interface{}) (int64, string, error) {
+	query, args, err := applyFilters(query, reader.o, reader.dbType)
+	if err != nil {
+		return 0, "", err
+	}
+	hasWhere := len(reader.o.filters) != 0
+
+	if previous != nil {
+		condition, keyArgs, err := computeKeysetCondition(reader.primaryKeys, reader.textKeys, ">", false, previous, reader.dbType, len(args)+1)
+		if err != nil {
+			return 0, "", err
+		}
+		query = appendCondition(query, hasWhere, condition)
+		args = append(args, keyArgs...)
+		hasWhere = true
+	}
+
+	condition, keyArgs, err := computeKeysetCondition(reader.primaryKeys, reader.textKeys, "<", true, reader.lastRaw, reader.dbType, len(args)+1)
+	if err != nil {
+		return 0, "", err
+	}
+	query = appendCondition(query, hasWhere, condition)
+	args = append(args, keyArgs...)
+
+	var count int64
+	var sum interface{}
+	if err := queryRow(reader.db, reader.o, []interface{}{&count, &sum}, query, args...); err != nil {
+		return 0, "", fmt.Errorf("table %s: %w", reader.tableName, classifyError(err))
+	}
+
+	if text, ok := textValue(sum); ok {
+		return count, text, nil
+	}
+	return count, fmt.Sprint(sum), nil
+}
+
+func hashRows(rows [][]interface{}) (string, error) {
+	hash := sha256.New()
+	for _, row := range rows {
+		if err := writeJSONLine(hash, row); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,190 @@
+package sqlutils
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompareTables(t *testing.T) {
+	db := openTestDB(t,
+		"CREATE TABLE a (id INTEGER PRIMARY KEY, name TEXT, score REAL)",
+		"CREATE TABLE b (id INTEGER PRIMARY KEY, name TEXT, score REAL)",
+		"INSERT INTO a VALUES (1, 'one', 1.5), (2, 'two', 2), (3, 'three', 3), (5, 'five', NULL)",
+		"INSERT INTO b VALUES (1, 'one', 1.5), (2, 'deux', 2), (4, 'four', 4), (5, 'five', 5)",
+	)
+
+	diff, err := CompareTables(db, "a", SQLite, db, "b", SQLite, WithChunkSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Equal() {
+		t.Fatal("CompareTables found the tables equal")
+	}
+	if diff.Unchanged != 1 {
+		t.Fatalf("Unchanged is %d, want 1", diff.Unchanged)
+	}
+	if len(diff.Added) != 1 || keyOf(diff.Added[0]) != 4 {
+		t.Fatalf("Added is %v, want the row 4", diff.Added)
+	}
+	if len(diff.Removed) != 1 || keyOf(diff.Removed[0]) != 3 {
+		t.Fatalf("Removed is %v, want the row 3", diff.Removed)
+	}
+
+	changed := map[int64][]string{}
+	for _, row := range diff.Changed {
+		for _, column := range row.Columns {
+			changed[keyOf(row.Key)] = append(changed[keyOf(row.Key)], column.Column)
+		}
+	}
+	want := map[int64][]string{2: {"name"}, 5: {"score"}}
+	if !reflect.DeepEqual(changed, want) {
+		t.Fatalf("changed columns are %v, want %v", changed, want)
+	}
+}
+
+func TestCompareTablesEqual(t *testing.T) {
+	db := openTestDB(t,
+		"CREATE TABLE a (code TEXT PRIMARY KEY, amount NUMERIC)",
+		"CREATE TABLE b (code TEXT PRIMARY KEY, amount NUMERIC)",
+		"INSERT INTO a VALUES ('B', 1), ('a', 2), ('b', 3.5)",
+		"INSERT INTO b VALUES ('b', 3.5), ('a', 2), ('B', 1)",
+	)
+
+	diff, err := CompareTables(db, "a", SQLite, db, "b", SQLite, WithChunkSize(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Equal() || diff.Unchanged != 3 {
+		t.Fatalf("CompareTables reported %+v, want 3 unchanged rows", diff)
+	}
+}
+
+func TestCompareTablesKeyOrder(t *testing.T) {
+	// without a declared type the column keeps integers and text, which
+	// SQLite sorts integers first
+	db := openTestDB(t,
+		"CREATE TABLE a (id PRIMARY KEY, name TEXT)",
+		"CREATE TABLE b (id PRIMARY KEY, name TEXT)",
+		"INSERT INTO a VALUES (10, 'ten'), ('9', 'nine')",
+		"INSERT INTO b VALUES (10, 'ten'), ('9', 'nine')",
+	)
+
+	_, err := CompareTables(db, "a", SQLite, db, "b", SQLite)
+	if !errors.Is(err, ErrKeyOrder) {
+		t.Fatalf("CompareTables returned %v, want ErrKeyOrder", err)
+	}
+}
+
+func TestTableChecksum(t *testing.T) {
+	db := openTestDB(t,
+		"CREATE TABLE a (id INTEGER PRIMARY KEY, x TEXT, y TEXT)",
+		"CREATE TABLE b (id INTEGER PRIMARY KEY, x TEXT, y TEXT)",
+		"INSERT INTO a VALUES (1, 'one', 'uno'), (2, 'two', NULL), (3, 'three', 'tres')",
+		"INSERT INTO b VALUES (3, 'three', 'tres'), (2, 'two', NULL), (1, 'one', 'uno')",
+	)
+
+	sumA, err := TableChecksum(db, "a", SQLite, WithChunkSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sumB, err := TableChecksum(db, "b", SQLite, WithChunkSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sumA.Rows != 3 || len(sumA.Chunks) != 2 {
+		t.Fatalf("checksum has %d rows in %d chunks, want 3 rows in 2 chunks", sumA.Rows, len(sumA.Chunks))
+	}
+	if sumA.Sum != sumB.Sum {
+		t.Fatalf("equal tables have the sums %s and %s", sumA.Sum, sumB.Sum)
+	}
+
+	if _, err := db.Exec("UPDATE b SET y = 'dos' WHERE id = 2"); err != nil {
+		t.Fatal(err)
+	}
+	sumB, err = TableChecksum(db, "b", SQLite, WithChunkSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sumA.Sum == sumB.Sum {
+		t.Fatal("different tables have the same sum")
+	}
+	if sumA.Chunks[0].Sum == sumB.Chunks[0].Sum || sumA.Chunks[1].Sum != sumB.Chunks[1].Sum {
+		t.Fatal("only the chunk of the changed row should differ")
+	}
+}
+
+func TestTableChecksumSeparatorsAndNull(t *testing.T) {
+	pairs := []struct{ a, b string }{
+		{"(1, 'a#', 'b')", "(1, 'a', '#b')"},
+		{"(1, '#NULL#', 'b')", "(1, NULL, 'b')"},
+		{"(1, '1:a', '')", "(1, '', '1:a')"},
+		{"(1, '-', 'b')", "(1, NULL, 'b')"},
+	}
+	for _, pair := range pairs {
+		db := openTestDB(t,
+			"CREATE TABLE a (id INTEGER PRIMARY KEY, x TEXT, y TEXT)",
+			"CREATE TABLE b (id INTEGER PRIMARY KEY, x TEXT, y TEXT)",
+			"INSERT INTO a VALUES "+pair.a,
+			"INSERT INTO b VALUES "+pair.b,
+		)
+		sumA, err := TableChecksum(db, "a", SQLite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sumB, err := TableChecksum(db, "b", SQLite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sumA.Sum == sumB.Sum {
+			t.Errorf("rows %s and %s have the same sum", pair.a, pair.b)
+		}
+	}
+}
+
+// The row text of the databases hashing the rows prefixes every value with
+// its length, so that separators and NULL cannot be confused with values.
+func TestChecksumQueryPrefixesLengths(t *testing.T) {
+	for _, dbType := range []DatabaseType{MySQL, MariaDB, PostgreSQL, CockroachDB} {
+		query, hashed, err := getQueryForChecksum("t", []string{"x", "y"}, []string{"id"}, dbType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !hashed {
+			t.Fatalf("%s does not hash the rows", dbType)
+		}
+		if strings.Contains(query, "#NULL#") || strings.Count(query, "':'") != 2 {
+			t.Errorf("%s row text is ambiguous: %s", dbType, query)
+		}
+	}
+}
+
+func TestChecksumQueryOracleGroupsColumns(t *testing.T) {
+	columns := make([]string, 250)
+	for index := range columns {
+		columns[index] = fmt.Sprint("C", index)
+	}
+	query, _, err := getQueryForChecksum("T", columns, nil, Oracle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(query, "ORA_HASH") {
+		t.Fatalf("Oracle sums ORA_HASH values: %s", query)
+	}
+	// 250 column hashes in 3 groups, then the hash of the groups
+	if got := strings.Count(query, "STANDARD_HASH"); got != 250+3+1 {
+		t.Fatalf("Oracle query has %d STANDARD_HASH calls, want 254", got)
+	}
+}
+
+func keyOf(record TableRecord) int64 {
+	switch id := record["id"].(type) {
+	case int64:
+		return id
+	case float64:
+		return int64(id)
+	}
+	return -1
+}
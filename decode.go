@@ -0,0 +1,253 @@
+package sqlutils
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How the values of a column are decoded, derived from the database type
+// name reported by the driver.
+type columnKind int
+
+const (
+	kindOther columnKind = iota
+	kindText
+	kindBinary
+	kindInteger
+	kindFloat
+	kindDecimal
+	kindBool
+	kindBit
+	kindTime
+	kindJSON
+	kindUUID
+)
+
+// Database type names as reported by the drivers, upper case and without
+// length or precision.
+var columnKindsByTypeName = map[string]columnKind{
+	"CHAR": kindText, "VARCHAR": kindText, "NCHAR": kindText, "NVARCHAR": kindText,
+	"VARCHAR2": kindText, "NVARCHAR2": kindText, "TEXT": kindText, "NTEXT": kindText,
+	"TINYTEXT": kindText, "MEDIUMTEXT": kindText, "LONGTEXT": kindText, "CLOB": kindText,
+	"NCLOB": kindText, "LONG": kindText, "BPCHAR": kindText, "CHARACTER": kindText,
+	"STRING": kindText, "CITEXT": kindText, "ENUM": kindText, "SET": kindText,
+	"XML": kindText, "ROWID": kindText,
+	"INET": kindText, "CIDR": kindText, "MACADDR": kindText, "INTERVAL": kindText,
+
+	"BLOB": kindBinary, "TINYBLOB": kindBinary, "MEDIUMBLOB": kindBinary, "LONGBLOB": kindBinary,
+	"BINARY": kindBinary, "VARBINARY": kindBinary, "BYTEA": kindBinary, "BYTES": kindBinary,
+	"IMAGE": kindBinary, "RAW": kindBinary, "LONG RAW": kindBinary, "GEOMETRY": kindBinary,
+
+	"INT": kindInteger, "INTEGER": kindInteger, "TINYINT": kindInteger, "SMALLINT": kindInteger,
+	"MEDIUMINT": kindInteger, "BIGINT": kindInteger, "INT2": kindInteger, "INT4": kindInteger,
+	"INT8": kindInteger, "SERIAL": kindInteger, "BIGSERIAL": kindInteger, "YEAR": kindInteger,
+	"UNSIGNED INT": kindInteger, "UNSIGNED TINYINT": kindInteger, "UNSIGNED SMALLINT": kindInteger,
+	"UNSIGNED MEDIUMINT": kindInteger, "UNSIGNED BIGINT": kindInteger,
+
+	"FLOAT": kindFloat, "DOUBLE": kindFloat, "REAL": kindFloat, "FLOAT4": kindFloat,
+	"FLOAT8": kindFloat, "DOUBLE PRECISION": kindFloat, "BINARY_FLOAT": kindFloat,
+	"BINARY_DOUBLE": kindFloat,
+
+	"DECIMAL": kindDecimal, "NUMERIC": kindDecimal, "NUMBER": kindDecimal, "MONEY": kindDecimal,
+	"SMALLMONEY": kindDecimal,
+
+	"BOOL": kindBool, "BOOLEAN": kindBool,
+	"BIT": kindBit,
+
+	"DATE": kindTime, "DATETIME": kindTime, "DATETIME2": kindTime, "SMALLDATETIME": kindTime,
+	"DATETIMEOFFSET": kindTime, "TIMESTAMP": kindTime, "TIMESTAMPTZ": kindTime, "TIME": kindTime,
+	"TIMETZ": kindTime, "TIMESTAMP WITH TIME ZONE": kindTime,
+	"TIMESTAMP WITH LOCAL TIME ZONE": kindTime,
+
+	"JSON": kindJSON, "JSONB": kindJSON,
+
+	"UUID": kindUUID, "UNIQUEIDENTIFIER": kindUUID,
+}
+
+// The decoding of one result column.
+type columnDecoding struct {
+	typeName string
+	kind     columnKind
+}
+
+func getColumnDecodings(columnTypes []*sql.ColumnType) []columnDecoding {
+	decodings := make([]columnDecoding, len(columnTypes))
+	for i, columnType := range columnTypes {
+		typeName := getColumnTypeName(columnType)
+		decodings[i] = columnDecoding{typeName: typeName, kind: columnKindsByTypeName[typeName]}
+
+		// TINYINT(1) is how MySQL and MariaDB store booleans
+		if length, ok := columnType.Length(); ok && length == 1 && typeName == "TINYINT" {
+			decodings[i].kind = kindBool
+		}
+	}
+	return decodings
+}
+
+// Returns the TINYINT(1) columns of the table, for the drivers reporting them
+// as TINYINT without their width. nil for the other dialects.
+func getBoolColumns(db *sql.DB, o *options, tableName string, databaseType DatabaseType) (map[string]bool, error) {
+	query, ok := getQueryForBoolColumns(databaseType)
+	if !ok {
+		return nil, nil
+	}
+
+	cache := &getTableCaches(db).boolColumns
+	if columns, ok := cache.Load(tableName); ok {
+		return columns.(map[string]bool), nil
+	}
+
+	rows, err := queryRows(db, o, query, tableName)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+
+	columns := map[string]bool{}
+	for rows.Next() {
+		var columnName string
+		if err := rows.Scan(&columnName); err != nil {
+			return nil, err
+		}
+		columns[columnName] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	cache.Store(tableName, columns)
+
+	return columns, nil
+}
+
+func getColumnTypeName(columnType *sql.ColumnType) string {
+	typeName := strings.ToUpper(strings.TrimSpace(columnType.DatabaseTypeName()))
+	if index := strings.IndexByte(typeName, '('); index != -1 {
+		typeName = strings.TrimSpace(typeName[:index])
+	}
+	return typeName
+}
+
+// WithBase64Binary returns binary columns as base64 encoded strings instead
+// of []byte, for consumers such as JSON encoders that cannot carry raw bytes.
+func WithBase64Binary() Option {
+	return func(o *options) {
+		o.base64Binary = true
+	}
+}
+
+// Converts a value scanned from a column to the Go type matching the column
+// type: text as string, binary as []byte, integers as int64 (uint64 above its
+// range), floats as float64 and booleans as bool. Times, decimals, JSON and
+// UUIDs follow the codec, binary UUIDs stay []byte without CanonicalUUID.
+// Values of unknown types are returned as the driver gives them, with []byte
+// turned into string.
+func decodeColumnValue(value interface{}, column columnDecoding, codec *ValueCodec, o *options) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	if decoder, ok := codec.Decoders[column.typeName]; ok {
+		return decoder(value)
+	}
+
+	switch column.kind {
+	case kindBinary:
+		if b, ok := value.([]byte); ok {
+			if o.base64Binary {
+				return base64.StdEncoding.EncodeToString(b), nil
+			}
+			return b, nil
+		}
+	case kindInteger:
+		if text, ok := textValue(value); ok {
+			text = strings.TrimSpace(text)
+			if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+				return i, nil
+			}
+			if u, err := strconv.ParseUint(text, 10, 64); err == nil {
+				return u, nil
+			}
+			return nil, fmt.Errorf("decoding integer %q", text)
+		}
+	case kindFloat:
+		if text, ok := textValue(value); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+			if err != nil {
+				return nil, fmt.Errorf("decoding float %q: %w", text, err)
+			}
+			return f, nil
+		}
+	case kindDecimal:
+		return codec.decodeDecimal(value)
+	case kindBool:
+		return decodeBool(value)
+	case kindBit:
+		// BIT(1) from MySQL, SQL Server drivers already return bool
+		if b, ok := value.([]byte); ok && len(b) == 1 && b[0] <= 1 {
+			return b[0] == 1, nil
+		}
+	case kindJSON:
+		if codec.ParseJSON {
+			return codec.decodeJSON(value)
+		}
+	case kindUUID:
+		if codec.CanonicalUUID {
+			return decodeUUID(value)
+		}
+		// the 16 bytes of a SQL Server UNIQUEIDENTIFIER are not text
+		if b, ok := value.([]byte); ok && len(b) == 16 {
+			if o.base64Binary {
+				return base64.StdEncoding.EncodeToString(b), nil
+			}
+			return b, nil
+		}
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return string(v), nil
+	case time.Time:
+		return codec.decodeTime(v), nil
+	default:
+		return v, nil
+	}
+}
+
+func textValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case []byte:
+		return string(v), true
+	case string:
+		return v, true
+	default:
+		return "", false
+	}
+}
+
+func decodeBool(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case int64:
+		return v != 0, nil
+	case []byte, string:
+		text, _ := textValue(v)
+		text = strings.TrimSpace(text)
+		// TINYINT(1) holds any value up to 127, all but 0 are true
+		if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return i != 0, nil
+		}
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("decoding boolean %q: %w", text, err)
+		}
+		return b, nil
+	default:
+		return v, nil
+	}
+}
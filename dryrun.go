@@ -0,0 +1,123 @@
+package sqlutils
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Statement is a rendered SQL statement with its bound arguments.
+type Statement struct {
+	SQL  string
+	Args []interface{}
+}
+
+// String returns the statement with the arguments interpolated, meant for
+// display only, the statement is always executed with bound arguments.
+func (s Statement) String() string {
+	return interpolateArgs(s.SQL, s.Args)
+}
+
+// Plan collects the statements a helper would have executed in dry-run mode.
+type Plan struct {
+	Statements []Statement
+}
+
+func (p *Plan) String() string {
+	rendered := make([]string, len(p.Statements))
+	for index, statement := range p.Statements {
+		rendered[index] = strings.TrimSuffix(statement.String(), ";") + ";"
+	}
+	return strings.Join(rendered, "\n")
+}
+
+// WithDryRun records the statements that would change data or schema into the
+// plan instead of executing them. Metadata needed to render them, such as
+// primary keys or columns, is still read from the database. Record helpers
+// report no affected rows and DDL helpers report the change they would make.
+func WithDryRun(plan *Plan) Option {
+	return func(o *options) {
+		o.dryRun = plan
+	}
+}
+
+// Replaces the ?, $1, @p1 and :1 placeholders outside of quoted strings and
+// identifiers with SQL literals of the arguments.
+func interpolateArgs(query string, args []interface{}) string {
+	var builder strings.Builder
+	nextArg := 0
+
+	for i := 0; i < len(query); i++ {
+		char := query[i]
+
+		switch char {
+		case '\'', '"', '`', '[':
+			closing := char
+			if char == '[' {
+				closing = ']'
+			}
+			end := strings.IndexByte(query[i+1:], closing)
+			if end < 0 {
+				builder.WriteString(query[i:])
+				return builder.String()
+			}
+			builder.WriteString(query[i : i+end+2])
+			i += end + 1
+			continue
+		case '?':
+			if nextArg < len(args) {
+				builder.WriteString(formatLiteral(args[nextArg]))
+				nextArg++
+				continue
+			}
+		case '$', '@', ':':
+			start := i + 1
+			if char == '@' && start < len(query) && query[start] == 'p' {
+				start++
+			}
+			end := start
+			for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+				end++
+			}
+			// a ':' right before is a cast such as $1::regclass
+			if end > start && !(char == ':' && i > 0 && query[i-1] == ':') {
+				position, _ := strconv.Atoi(query[start:end])
+				if position >= 1 && position <= len(args) {
+					builder.WriteString(formatLiteral(args[position-1]))
+					i = end - 1
+					continue
+				}
+			}
+		}
+
+		builder.WriteByte(char)
+	}
+
+	return builder.String()
+}
+
+func formatLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case []byte:
+		return "X'" + hex.EncodeToString(v) + "'"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case time.Time:
+		return "'" + v.Format("2006-01-02 15:04:05.999999999Z07:00") + "'"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	case fmt.Stringer:
+		return formatLiteral(v.String())
+	default:
+		return formatLiteral(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,361 @@
+package sqlutils
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// The format name and version of the dumps written by Dump.
+const (
+	dumpFormat  = "sqlutils-dump"
+	dumpVersion = 1
+)
+
+// DumpManifest is the first line of a dump, it describes the tables whose
+// records follow, in the order they are restored.
+type DumpManifest struct {
+	Format       string        `json:"format"`
+	Version      int           `json:"version"`
+	DatabaseType DatabaseType  `json:"database_type"`
+	CreatedAt    time.Time     `json:"created_at"`
+	Tables       []TableSchema `json:"tables"`
+}
+
+// The values of a dump: times with their zone, decimals exact, JSON as its
+// text and binary as base64, which the JSON encoder does.
+var dumpValueCodec = &ValueCodec{
+	TimeFormat:      time.RFC3339Nano,
+	DecimalAsString: true,
+	CanonicalUUID:   true,
+}
+
+// WithTables limits Dump and Restore to the tables.
+func WithTables(tableNames ...string) Option {
+	return func(o *options) {
+		o.tables = tableNames
+	}
+}
+
+// RestoreReport is the outcome of Restore.
+type RestoreReport struct {
+	// Created lists the tables created, in order.
+	Created []string
+	// Tables holds the outcome of loading the records of each table.
+	Tables map[string]ImportReport
+}
+
+// Dump writes the schema and the records of the tables of the connected
+// database to w, tables referenced by foreign keys first. WithTables picks
+// the tables, all of them but the trashed ones otherwise.
+//
+// A dump is JSON lines: a DumpManifest, then for each table a {"table": name}
+// line followed by one array of values per record, in the column order of
+// the manifest.
+func Dump(db *sql.DB, dbType DatabaseType, w io.Writer, opts ...Option) error {
+	o := newOptions(db, OpDump, "", opts)
+
+	tableNames := o.tables
+	if len(tableNames) == 0 {
+		allTableNames, err := GetTables(db, "", dbType, inherit(o))
+		if err != nil {
+			return fmt.Errorf("Dump - %w", err)
+		}
+		for _, tableName := range allTableNames {
+			if _, trashed := parseTrashTableName(tableName); trashed {
+				continue
+			}
+			if dbType == SQLite && strings.HasPrefix(tableName, "sqlite_") {
+				continue
+			}
+			tableNames = append(tableNames, tableName)
+		}
+	}
+
+	if err := checkGuard(o, tableNames...); err != nil {
+		return fmt.Errorf("Dump - %w", err)
+	}
+
+	tables := make([]TableSchema, len(tableNames))
+	for i, tableName := range tableNames {
+		table, err := DescribeTable(db, tableName, dbType, inherit(o))
+		if err != nil {
+			return fmt.Errorf("Dump - %w", err)
+		}
+		tables[i] = table
+	}
+	tables = sortTablesByDependencies(tables)
+
+	manifest := DumpManifest{
+		Format:       dumpFormat,
+		Version:      dumpVersion,
+		DatabaseType: dbType,
+		CreatedAt:    time.Now().UTC(),
+		Tables:       tables,
+	}
+	if err := writeJSONLine(w, manifest); err != nil {
+		return fmt.Errorf("Dump - %w", err)
+	}
+
+	for _, table := range tables {
+		columnNames := make([]string, len(table.Columns))
+		for i, column := range table.Columns {
+			columnNames[i] = column.Name
+		}
+
+		tableOptions := *o
+		tableOptions.table = table.Name
+		tableOptions.codec = dumpValueCodec
+		tableOptions.columns = columnNames
+		tableOptions.filters = nil
+		tableOptions.base64Binary = false
+
+		_, err := exportTable(db, &tableOptions, table.Name, dbType, &dumpExporter{tableName: table.Name}, w)
+		if err != nil {
+			return fmt.Errorf("Dump - table %s: %w", table.Name, err)
+		}
+	}
+
+	return nil
+}
+
+type dumpTableHeader struct {
+	Table string `json:"table"`
+}
+
+func writeJSONLine(w io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(value)
+}
+
+// Writes the table line, then one array of values per record.
+type dumpExporter struct {
+	tableName string
+}
+
+func (e *dumpExporter) begin(w io.Writer, _ []string) error {
+	return writeJSONLine(w, dumpTableHeader{Table: e.tableName})
+}
+
+func (e *dumpExporter) write(w io.Writer, row []interface{}) error {
+	return writeJSONLine(w, row)
+}
+
+func (e *dumpExporter) end(io.Writer) error {
+	return nil
+}
+
+// Restore creates the tables of a dump written by Dump and loads their
+// records, converting the column types when the dump comes from another
+// dialect. WithTables picks the tables, WithIfNotExists loads the records of
+// tables that already exist instead of failing, and WithImportMode and
+// WithBatchSize apply as for ImportTable. Foreign keys are only created
+// between the restored tables, and auto increment columns are restored as
+// plain columns.
+func Restore(db *sql.DB, dbType DatabaseType, r io.Reader, opts ...Option) (RestoreReport, error) {
+	o := newOptions(db, OpRestore, "", opts)
+	report := RestoreReport{Tables: map[string]ImportReport{}}
+
+	reader := bufio.NewReader(r)
+	line := 1
+
+	content, err := reader.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return report, fmt.Errorf("Restore - reading manifest: %w", err)
+	}
+
+	var manifest DumpManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return report, fmt.Errorf("Restore - reading manifest: %w", err)
+	}
+	if manifest.Format != dumpFormat || manifest.Version > dumpVersion {
+		return report, fmt.Errorf("Restore - unsupported dump format %s version %d", manifest.Format, manifest.Version)
+	}
+
+	tables, err := selectDumpTables(manifest.Tables, o.tables)
+	if err != nil {
+		return report, fmt.Errorf("Restore - %w", err)
+	}
+
+	tableNames := make([]string, len(tables))
+	for i, table := range tables {
+		tableNames[i] = table.Name
+	}
+	if err := checkGuard(o, tableNames...); err != nil {
+		return report, fmt.Errorf("Restore - %w", err)
+	}
+
+	created, err := createDumpTables(db, o, tables, manifest.DatabaseType, dbType)
+	report.Created = created
+	if err != nil {
+		return report, fmt.Errorf("Restore - %w", err)
+	}
+
+	importers := make(map[string]*tableImporter, len(tables))
+	for _, table := range tables {
+		importer, err := newImporterFor(db, o, table.Name, dbType)
+		if err != nil {
+			return report, fmt.Errorf("Restore - %w", err)
+		}
+		if importer.mode != ImportInsert {
+			if err := importer.setPrimaryKeys(table.PrimaryKeys); err != nil {
+				return report, fmt.Errorf("Restore - %w", err)
+			}
+		}
+
+		importer.decodings = make(map[string]columnDecoding, len(table.Columns))
+		for _, column := range table.Columns {
+			importer.decodings[column.Name] = columnDecoding{typeName: column.Type, kind: columnSchemaKind(column)}
+		}
+		importers[table.Name] = importer
+	}
+
+	columnsByTable := make(map[string][]ColumnSchema, len(manifest.Tables))
+	for _, table := range manifest.Tables {
+		columnsByTable[table.Name] = table.Columns
+	}
+
+	var current *tableImporter
+	var columns []ColumnSchema
+	finish := func() error {
+		if current == nil {
+			return nil
+		}
+		err := current.flush()
+		report.Tables[current.tableName] = current.report
+		return err
+	}
+
+	for {
+		content, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return report, fmt.Errorf("Restore - line %d: %w", line+1, readErr)
+		}
+		line++
+
+		content = bytes.TrimSpace(content)
+		switch {
+		case len(content) == 0:
+		case content[0] == '{':
+			if err := finish(); err != nil {
+				return report, fmt.Errorf("Restore - %w", err)
+			}
+
+			var header dumpTableHeader
+			if err := json.Unmarshal(content, &header); err != nil {
+				return report, fmt.Errorf("Restore - line %d: %w", line, err)
+			}
+			current = importers[header.Table]
+			columns = columnsByTable[header.Table]
+		case current != nil:
+			decoder := json.NewDecoder(bytes.NewReader(content))
+			decoder.UseNumber()
+
+			var values []interface{}
+			if err := decoder.Decode(&values); err != nil {
+				return report, fmt.Errorf("Restore - line %d: %w", line, err)
+			}
+			if len(values) != len(columns) {
+				current.report.Failed = append(current.report.Failed, ImportIssue{
+					Line:   line,
+					Reason: fmt.Sprintf("expected %d values, got %d", len(columns), len(values)),
+				})
+				break
+			}
+
+			record := make(TableRecord, len(values))
+			var convertErr error
+			for i, column := range columns {
+				record[column.Name], convertErr = convertImportValue(values[i], current.decodings[column.Name], o)
+				if convertErr != nil {
+					convertErr = fmt.Errorf("column %s: %w", column.Name, convertErr)
+					break
+				}
+			}
+			if err := current.add(line, record, convertErr); err != nil {
+				return report, fmt.Errorf("Restore - %w", err)
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	if err := finish(); err != nil {
+		return report, fmt.Errorf("Restore - %w", err)
+	}
+
+	err = writeAudit(o, AuditEntry{Details: map[string]interface{}{
+		"tables":  tableNames,
+		"created": report.Created,
+	}})
+	if err != nil {
+		return report, fmt.Errorf("Restore - %w", err)
+	}
+
+	return report, nil
+}
+
+// Keeps the tables of the dump that are asked for, all of them when none
+// are.
+func selectDumpTables(tables []TableSchema, tableNames []string) ([]TableSchema, error) {
+	if len(tableNames) == 0 {
+		return tables, nil
+	}
+
+	wanted := make(map[string]bool, len(tableNames))
+	for _, tableName := range tableNames {
+		wanted[tableName] = true
+	}
+
+	var selected []TableSchema
+	for _, table := range tables {
+		if wanted[table.Name] {
+			selected = append(selected, table)
+			delete(wanted, table.Name)
+		}
+	}
+
+	for _, tableName := range tableNames {
+		if wanted[tableName] {
+			return nil, fmt.Errorf("table %s is not in the dump", tableName)
+		}
+	}
+
+	return selected, nil
+}
+
+// Creates the tables in order, with the foreign keys to the tables created
+// before them. With WithIfNotExists existing tables are left as they are.
+func createDumpTables(db *sql.DB, o *options, tables []TableSchema, source, target DatabaseType) ([]string, error) {
+	var created []string
+	restored := make(map[string]bool, len(tables))
+
+	for _, table := range tables {
+		restored[table.Name] = true
+
+		var foreignKeys []ForeignKey
+		for _, foreignKey := range table.ForeignKeys {
+			if restored[foreignKey.ReferencedTable] {
+				foreignKeys = append(foreignKeys, foreignKey)
+			}
+		}
+
+		changed, err := createTable(db, o, table, foreignKeys, source, target)
+		if err != nil {
+			return created, fmt.Errorf("creating table %s: %w", table.Name, err)
+		}
+		if changed {
+			created = append(created, table.Name)
+		}
+	}
+
+	return created, nil
+}
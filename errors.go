@@ -0,0 +1,192 @@
+package sqlutils
+
+import (
+	"errors"
+	"strings"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/godror/godror"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	ErrUnsupportedDatabase = errors.New("unsupported database type")
+	ErrTableNotFound       = errors.New("table not found")
+	ErrTableExists         = errors.New("table already exists")
+	ErrNoRowsAffected      = errors.New("no rows affected")
+	ErrPrimaryKeyMissing   = errors.New("primary key not provided")
+	ErrInvalidIdentifier   = errors.New("invalid identifier")
+	ErrOperationDenied     = errors.New("operation denied")
+	ErrUndoConflict        = errors.New("rows changed since the undo token was taken")
+	ErrDeleteLimit         = errors.New("delete limit reached")
+	ErrKeyOrder            = errors.New("database sorts the primary keys differently")
+	ErrNoPrimaryKey        = errors.New("table has no primary key")
+)
+
+// Kinds of driver errors, matched with errors.Is.
+var (
+	ErrUniqueViolation      = errors.New("unique constraint violation")
+	ErrForeignKeyViolation  = errors.New("foreign key constraint violation")
+	ErrNotNullViolation     = errors.New("not null constraint violation")
+	ErrDeadlock             = errors.New("deadlock")
+	ErrSerializationFailure = errors.New("serialization failure")
+)
+
+// DriverError wraps an error returned by one of the database drivers together
+// with its driver independent kind, one of the Err...Violation, ErrDeadlock,
+// ErrSerializationFailure, ErrTableNotFound or ErrTableExists values. The original driver
+// error stays reachable through errors.As.
+type DriverError struct {
+	Kind error
+	Err  error
+}
+
+func (e *DriverError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DriverError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DriverError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Wraps driver errors of a known kind into a DriverError, other errors are
+// returned as they are.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var driverError *DriverError
+	if errors.As(err, &driverError) {
+		return err
+	}
+
+	if kind := driverErrorKind(err); kind != nil {
+		return &DriverError{Kind: kind, Err: err}
+	}
+
+	return err
+}
+
+func driverErrorKind(err error) error {
+	// PostgreSQL and CockroachDB
+	var pqError *pq.Error
+	if errors.As(err, &pqError) {
+		switch pqError.Code {
+		case "23505":
+			return ErrUniqueViolation
+		case "23503":
+			return ErrForeignKeyViolation
+		case "23502":
+			return ErrNotNullViolation
+		case "40P01":
+			return ErrDeadlock
+		case "40001":
+			return ErrSerializationFailure
+		case "42P01":
+			return ErrTableNotFound
+		case "42P07":
+			return ErrTableExists
+		}
+		return nil
+	}
+
+	// MySQL and MariaDB
+	var mysqlError *mysql.MySQLError
+	if errors.As(err, &mysqlError) {
+		switch mysqlError.Number {
+		case 1062, 1586:
+			return ErrUniqueViolation
+		case 1216, 1217, 1451, 1452:
+			return ErrForeignKeyViolation
+		case 1048, 1364:
+			return ErrNotNullViolation
+		case 1213:
+			return ErrDeadlock
+		case 1146:
+			return ErrTableNotFound
+		case 1050:
+			return ErrTableExists
+		}
+		return nil
+	}
+
+	var sqliteError sqlite3.Error
+	if errors.As(err, &sqliteError) {
+		switch sqliteError.ExtendedCode {
+		case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+			return ErrUniqueViolation
+		case sqlite3.ErrConstraintForeignKey:
+			return ErrForeignKeyViolation
+		case sqlite3.ErrConstraintNotNull:
+			return ErrNotNullViolation
+		case sqlite3.ErrBusySnapshot:
+			return ErrSerializationFailure
+		}
+		switch sqliteError.Code {
+		// SQLite does not detect deadlocks, a lock it cannot get is the closest
+		case sqlite3.ErrBusy, sqlite3.ErrLocked:
+			return ErrDeadlock
+		case sqlite3.ErrError:
+			message := sqliteError.Error()
+			switch {
+			case strings.HasPrefix(message, "no such table"):
+				return ErrTableNotFound
+			case strings.HasPrefix(message, "table ") && strings.HasSuffix(message, "already exists"),
+				strings.HasPrefix(message, "there is already another table"):
+				return ErrTableExists
+			}
+		}
+		return nil
+	}
+
+	var mssqlError mssql.Error
+	if errors.As(err, &mssqlError) {
+		switch mssqlError.Number {
+		case 2601, 2627:
+			return ErrUniqueViolation
+		case 547:
+			return ErrForeignKeyViolation
+		case 515:
+			return ErrNotNullViolation
+		case 1205:
+			return ErrDeadlock
+		case 3960:
+			return ErrSerializationFailure
+		// 15248 and 15335 are raised by sp_rename
+		case 208, 15248:
+			return ErrTableNotFound
+		case 2714, 15335:
+			return ErrTableExists
+		}
+		return nil
+	}
+
+	if oraError, ok := godror.AsOraErr(err); ok {
+		switch oraError.Code() {
+		case 1:
+			return ErrUniqueViolation
+		case 2291, 2292:
+			return ErrForeignKeyViolation
+		case 1400, 1407:
+			return ErrNotNullViolation
+		case 60:
+			return ErrDeadlock
+		case 8177:
+			return ErrSerializationFailure
+		case 942:
+			return ErrTableNotFound
+		case 955:
+			return ErrTableExists
+		}
+		return nil
+	}
+
+	return nil
+}
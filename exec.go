@@ -0,0 +1,118 @@
+package sqlutils
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Every statement that changes data or schema goes through here, so that the
+// call options can intercept it.
+func execStatement(e execer, o *options, query string, args ...interface{}) (sql.Result, error) {
+	if o.dryRun != nil {
+		o.dryRun.Statements = append(o.dryRun.Statements, Statement{SQL: query, Args: args})
+		return dryRunResult{}, nil
+	}
+
+	var result sql.Result
+	err := runWithHooks(o, query, args, func(ctx context.Context) (int64, error) {
+		var err error
+		result, err = e.ExecContext(ctx, query, args...)
+		if err != nil {
+			return -1, err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return -1, nil
+		}
+		return rowsAffected, nil
+	})
+
+	return result, err
+}
+
+// Every query reading data or metadata goes through here. The hooks learn
+// about the query once its rows are closed, so that they see the time spent
+// fetching them and the errors met while iterating.
+func queryRows(e execer, o *options, query string, args ...interface{}) (*hookedRows, error) {
+	ctx, finish := startHooks(o, query, args)
+
+	rows, err := e.QueryContext(ctx, query, args...)
+	if err != nil {
+		finish(-1, err)
+		return nil, err
+	}
+
+	return &hookedRows{Rows: rows, finish: finish}, nil
+}
+
+// Rows calling AfterQuery of the hooks when they are closed.
+type hookedRows struct {
+	*sql.Rows
+	finish func(rowsAffected int64, err error)
+}
+
+func (r *hookedRows) Close() error {
+	err := r.Rows.Close()
+	if r.finish != nil {
+		finishErr := r.Rows.Err()
+		if finishErr == nil {
+			finishErr = err
+		}
+		r.finish(-1, finishErr)
+		r.finish = nil
+	}
+	return err
+}
+
+// Scans the single row returned by the query into dest.
+func queryRow(e execer, o *options, dest []interface{}, query string, args ...interface{}) error {
+	rows, err := queryRows(e, o, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+
+	return rows.Close()
+}
+
+type dryRunResult struct{}
+
+func (dryRunResult) LastInsertId() (int64, error) { return 0, nil }
+func (dryRunResult) RowsAffected() (int64, error) { return 0, nil }
+
+// Runs fn in a transaction when enabled, directly against the connection
+// otherwise. The transaction is rolled back when fn fails.
+func inTransaction(db *sql.DB, o *options, enabled bool, fn func(e execer) error) error {
+	if !enabled || o.dryRun != nil {
+		return fn(db)
+	}
+
+	tx, err := db.BeginTx(o.ctx, nil)
+	if err != nil {
+		return classifyError(err)
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return classifyError(tx.Commit())
+}
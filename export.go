@@ -0,0 +1,456 @@
+package sqlutils
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DataFormat is a file format tables are exported to or imported from.
+type DataFormat string
+
+const (
+	FormatCSV    DataFormat = "csv"
+	FormatJSON   DataFormat = "json"
+	FormatNDJSON DataFormat = "ndjson"
+	// FormatSQL is a script of INSERT statements, export only.
+	FormatSQL DataFormat = "sql"
+)
+
+// WithColumns limits the call to the columns, in this order.
+func WithColumns(columns ...string) Option {
+	return func(o *options) {
+		o.columns = columns
+	}
+}
+
+// WithCSVDelimiter separates the CSV fields with the rune instead of a comma.
+func WithCSVDelimiter(delimiter rune) Option {
+	return func(o *options) {
+		o.csvDelimiter = delimiter
+	}
+}
+
+// WithoutCSVHeader leaves out the header line of the CSV files, the columns
+// are then taken in the order of the table or of WithColumns.
+func WithoutCSVHeader() Option {
+	return func(o *options) {
+		o.csvNoHeader = true
+	}
+}
+
+// WithNullString represents NULL in CSV files with the string, empty by
+// default.
+func WithNullString(null string) Option {
+	return func(o *options) {
+		o.nullString = null
+	}
+}
+
+// ExportTable writes the records of the table to w in the format, one row at
+// a time. WithColumns picks the columns, WithFilters the records, the values
+// are decoded like GetTable does except for times, written with their
+// fractional seconds. It returns the number of records written.
+func ExportTable(
+	db *sql.DB,
+	tableName string,
+	dbType DatabaseType,
+	format DataFormat,
+	w io.Writer,
+	opts ...Option,
+) (int64, error) {
+	o := newOptions(db, OpExportTable, tableName, opts)
+
+	if err := checkGuard(o, tableName); err != nil {
+		return 0, fmt.Errorf("ExportTable - %w", err)
+	}
+
+	var exporter rowExporter
+	switch format {
+	case FormatCSV:
+		exporter = &csvExporter{o: o}
+	case FormatJSON:
+		exporter = &jsonExporter{array: true}
+	case FormatNDJSON:
+		exporter = &jsonExporter{}
+	case FormatSQL:
+		exporter = &sqlExporter{tableName: tableName, dbType: dbType}
+	default:
+		return 0, fmt.Errorf("ExportTable - unsupported format %q", format)
+	}
+
+	count, err := exportTable(db, o, tableName, dbType, exporter, w)
+	if err != nil {
+		return count, fmt.Errorf("ExportTable - %w", err)
+	}
+
+	return count, nil
+}
+
+// Streams the records of the table through the exporter.
+func exportTable(
+	db *sql.DB,
+	o *options,
+	tableName string,
+	dbType DatabaseType,
+	exporter rowExporter,
+	w io.Writer,
+) (int64, error) {
+	rows, decodings, err := selectForExport(db, o, tableName, dbType)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, fmt.Errorf("retrieving columns: %w", err)
+	}
+
+	buffered := bufio.NewWriter(w)
+	if err := exporter.begin(buffered, columns); err != nil {
+		return 0, err
+	}
+
+	codec := getExportValueCodec(o)
+
+	var count int64
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return count, fmt.Errorf("scanning row: %w", err)
+		}
+
+		row := make([]interface{}, len(columns))
+		for i, column := range columns {
+			row[i], err = decodeColumnValue(values[i], decodings[i], codec, o)
+			if err != nil {
+				return count, fmt.Errorf("column %s: %w", column, err)
+			}
+		}
+
+		if row, err = maskRow(o, tableName, columns, nil, row); err != nil {
+			return count, err
+		}
+
+		if err := exporter.write(buffered, row); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("rows iteration: %w", err)
+	}
+
+	if err := exporter.end(buffered); err != nil {
+		return count, err
+	}
+
+	return count, buffered.Flush()
+}
+
+// The decoding of exports without WithValueCodec, the default one except for
+// times, kept as time.Time for each format to render them in full.
+var exportValueCodec = &ValueCodec{
+	DecimalAsString: true,
+	ParseJSON:       true,
+}
+
+func getExportValueCodec(o *options) *ValueCodec {
+	if o.codec != nil {
+		return o.codec
+	}
+	return exportValueCodec
+}
+
+// Queries the records to export along with the decoding of their columns.
+func selectForExport(db *sql.DB, o *options, tableName string, dbType DatabaseType) (*hookedRows, []columnDecoding, error) {
+	columns := []string{"*"}
+	if len(o.columns) != 0 {
+		if _, err := validateRecordColumns(db, o, tableName, nil, dbType, o.columns...); err != nil {
+			return nil, nil, err
+		}
+
+		quotedColumns, err := quoteIdentifiers(o.columns, dbType)
+		if err != nil {
+			return nil, nil, err
+		}
+		columns = quotedColumns
+	}
+
+	query, err := getQueryForSelectColumns(tableName, columns, dbType)
+	if err != nil {
+		return nil, nil, fmt.Errorf("grabbing db type specific query: %w", err)
+	}
+	query, args, err := applyFilters(query, o, dbType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	codec := getExportValueCodec(o)
+
+	var jsonColumns map[string]bool
+	if codec.ParseJSON {
+		jsonColumns, err = getJSONColumns(db, o, tableName, dbType)
+		if err != nil {
+			return nil, nil, fmt.Errorf("grabbing JSON columns: %w", err)
+		}
+	}
+
+	boolColumns, err := getBoolColumns(db, o, tableName, dbType)
+	if err != nil {
+		return nil, nil, fmt.Errorf("grabbing boolean columns: %w", err)
+	}
+
+	rows, err := queryRows(db, o, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query: %w", classifyError(err))
+	}
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		rows.Close()
+		return nil, nil, fmt.Errorf("retrieving column types: %w", err)
+	}
+
+	decodings := getColumnDecodings(columnTypes)
+	for i, columnType := range columnTypes {
+		if jsonColumns[columnType.Name()] {
+			decodings[i].kind = kindJSON
+		}
+		if boolColumns[columnType.Name()] {
+			decodings[i].kind = kindBool
+		}
+	}
+
+	return rows, decodings, nil
+}
+
+type rowExporter interface {
+	begin(w io.Writer, columns []string) error
+	write(w io.Writer, row []interface{}) error
+	end(w io.Writer) error
+}
+
+type csvExporter struct {
+	o      *options
+	writer *csv.Writer
+}
+
+func (e *csvExporter) begin(w io.Writer, columns []string) error {
+	e.writer = csv.NewWriter(w)
+	if e.o.csvDelimiter != 0 {
+		e.writer.Comma = e.o.csvDelimiter
+	}
+	if e.o.csvNoHeader {
+		return nil
+	}
+	return e.writer.Write(columns)
+}
+
+func (e *csvExporter) write(_ io.Writer, row []interface{}) error {
+	record := make([]string, len(row))
+	for i, value := range row {
+		field, err := formatCSVField(value, e.o.nullString)
+		if err != nil {
+			return err
+		}
+		record[i] = field
+	}
+	return e.writer.Write(record)
+}
+
+func (e *csvExporter) end(io.Writer) error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func formatCSVField(value interface{}, null string) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return null, nil
+	case string:
+		return v, nil
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case map[string]interface{}, []interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(encoded), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// Writes objects keyed by column in the order of the columns, as a JSON array
+// or one per line.
+type jsonExporter struct {
+	array   bool
+	columns []string
+	count   int
+}
+
+func (e *jsonExporter) begin(w io.Writer, columns []string) error {
+	e.columns = make([]string, len(columns))
+	for i, column := range columns {
+		encoded, err := json.Marshal(column)
+		if err != nil {
+			return err
+		}
+		e.columns[i] = string(encoded)
+	}
+
+	if e.array {
+		_, err := io.WriteString(w, "[")
+		return err
+	}
+	return nil
+}
+
+func (e *jsonExporter) write(w io.Writer, row []interface{}) error {
+	var b strings.Builder
+	if e.array && e.count > 0 {
+		b.WriteString(",")
+	}
+	if e.array {
+		b.WriteString("\n")
+	}
+
+	b.WriteString("{")
+	for i, value := range row {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		b.WriteString(e.columns[i])
+		b.WriteString(":")
+		b.Write(encoded)
+	}
+	b.WriteString("}")
+
+	if !e.array {
+		b.WriteString("\n")
+	}
+
+	e.count++
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (e *jsonExporter) end(w io.Writer) error {
+	if !e.array {
+		return nil
+	}
+	closing := "]\n"
+	if e.count > 0 {
+		closing = "\n]\n"
+	}
+	_, err := io.WriteString(w, closing)
+	return err
+}
+
+// Writes one INSERT statement per record, with the literals of the dialect.
+type sqlExporter struct {
+	tableName string
+	dbType    DatabaseType
+	prefix    string
+}
+
+func (e *sqlExporter) begin(_ io.Writer, columns []string) error {
+	quotedTableName, err := quoteIdentifier(e.tableName, e.dbType)
+	if err != nil {
+		return err
+	}
+	quotedColumns, err := quoteIdentifiers(columns, e.dbType)
+	if err != nil {
+		return err
+	}
+
+	e.prefix = fmt.Sprintf("INSERT INTO %s (%s) VALUES (", quotedTableName, strings.Join(quotedColumns, ", "))
+	return nil
+}
+
+func (e *sqlExporter) write(w io.Writer, row []interface{}) error {
+	literals := make([]string, len(row))
+	for i, value := range row {
+		literal, err := formatDialectLiteral(value, e.dbType)
+		if err != nil {
+			return err
+		}
+		literals[i] = literal
+	}
+
+	_, err := io.WriteString(w, e.prefix+strings.Join(literals, ", ")+");\n")
+	return err
+}
+
+func (e *sqlExporter) end(io.Writer) error {
+	return nil
+}
+
+// Renders the value as a SQL literal of the dialect, for the values that
+// formatLiteral does not render portably.
+func formatDialectLiteral(value interface{}, dbType DatabaseType) (string, error) {
+	switch v := value.(type) {
+	case []byte:
+		switch dbType {
+		case PostgreSQL:
+			return "'\\x" + hex.EncodeToString(v) + "'::bytea", nil
+		case SQLServer:
+			return "0x" + hex.EncodeToString(v), nil
+		case Oracle:
+			return "HEXTORAW('" + hex.EncodeToString(v) + "')", nil
+		}
+	case bool:
+		if dbType == SQLServer || dbType == Oracle {
+			if v {
+				return "1", nil
+			}
+			return "0", nil
+		}
+	case string:
+		if dbType == SQLServer {
+			return "N" + formatLiteral(v), nil
+		}
+	case time.Time:
+		switch dbType {
+		case Oracle:
+			// a literal with a zone, converted to the type of the column
+			return "TIMESTAMP '" + v.UTC().Format("2006-01-02 15:04:05.999999999") + " +00:00'", nil
+		case SQLServer:
+			// ISO 8601, the only format with a zone read regardless of the language
+			return "'" + v.Format("2006-01-02T15:04:05.9999999Z07:00") + "'", nil
+		}
+	case map[string]interface{}, []interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return formatDialectLiteral(string(encoded), dbType)
+	}
+
+	return formatLiteral(value), nil
+}
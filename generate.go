@@ -0,0 +1,783 @@
+package sqlutils
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// parent keys a foreign key picks from
+	maxParentKeys = 10000
+	// draws of a row before giving up on its unique keys
+	maxGenerateAttempts = 100
+)
+
+// ValueGenerator returns the value of a column for the row at the index,
+// drawing from the random source of the call so that WithSeed reproduces it.
+type ValueGenerator func(r *rand.Rand, row int) (interface{}, error)
+
+// WithSeed makes GenerateRows draw the same values on every run.
+func WithSeed(seed int64) Option {
+	return func(o *options) {
+		o.seed = seed
+		o.seeded = true
+	}
+}
+
+// WithGenerator fills the column with the values of the generator instead of
+// the ones derived from its type.
+func WithGenerator(column string, generator ValueGenerator) Option {
+	return func(o *options) {
+		generators := make(map[string]ValueGenerator, len(o.generators)+1)
+		for name, existing := range o.generators {
+			generators[name] = existing
+		}
+		generators[column] = generator
+		o.generators = generators
+	}
+}
+
+var (
+	firstNames = []string{
+		"James", "Mary", "Robert", "Patricia", "John", "Jennifer", "Michael", "Linda", "David", "Elizabeth",
+		"William", "Barbara", "Richard", "Susan", "Joseph", "Jessica", "Thomas", "Sarah", "Charles", "Karen",
+	}
+	lastNames = []string{
+		"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez",
+		"Hernandez", "Lopez", "Gonzalez", "Wilson", "Anderson", "Thomas", "Taylor", "Moore", "Jackson", "Martin",
+	}
+	cities = []string{
+		"London", "Paris", "Berlin", "Madrid", "Rome", "Vienna", "Lisbon", "Dublin", "Prague", "Warsaw",
+		"New York", "Chicago", "Toronto", "Sydney", "Tokyo", "Seoul", "Singapore", "Mumbai", "Cairo", "Lima",
+	}
+	countries = []string{
+		"United Kingdom", "France", "Germany", "Spain", "Italy", "Austria", "Portugal", "Ireland", "Poland",
+		"United States", "Canada", "Australia", "Japan", "Brazil", "India", "Mexico", "Sweden", "Norway",
+	}
+	words = []string{
+		"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel", "india", "juliet",
+		"kilo", "lima", "mike", "november", "oscar", "papa", "quebec", "romeo", "sierra", "tango",
+	}
+)
+
+// Times are drawn from the five years after it, not from the current time,
+// so that a seed reproduces them.
+var generatedTimeBase = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// GenerateRows inserts count rows of made up values into the table, loaded
+// with BulkLoad. Values follow the type, length, precision and nullability of
+// each column; text columns get names, emails, cities and the like after
+// their name and ENUM and SET columns one of their members. Primary keys and
+// unique constraints get distinct values, those made of a single numeric
+// column continue after the largest value in the table, the others being
+// checked against the keys of the existing rows. Foreign key columns take the
+// keys of existing parent rows.
+//
+// WithSeed reproduces the values of a run, WithGenerator replaces the values
+// of a column and WithColumns limits the columns filled, the others being
+// left to their defaults, such as identity columns.
+func GenerateRows(db *sql.DB, tableName string, dbType DatabaseType, count int, opts ...Option) (int64, error) {
+	o := newOptions(db, OpGenerateRows, tableName, opts)
+
+	if err := checkGuard(o, tableName); err != nil {
+		return 0, fmt.Errorf("GenerateRows - %w", err)
+	}
+
+	table, err := DescribeTable(db, tableName, dbType, inherit(o))
+	if err != nil {
+		return 0, fmt.Errorf("GenerateRows - %w", err)
+	}
+
+	generator, err := newRowGenerator(db, o, table, dbType)
+	if err != nil {
+		return 0, fmt.Errorf("GenerateRows - %w", err)
+	}
+
+	columnNames := make([]string, len(generator.columns))
+	for i, column := range generator.columns {
+		columnNames[i] = column.column.Name
+	}
+
+	row := 0
+	rows := RowSourceFunc(func() ([]interface{}, error) {
+		if row == count {
+			return nil, io.EOF
+		}
+		values, err := generator.row(row)
+		row++
+		return values, err
+	})
+
+	inserted, err := BulkLoad(db, tableName, dbType, columnNames, rows, inherit(o))
+	if err != nil {
+		return inserted, fmt.Errorf("GenerateRows - %w", err)
+	}
+
+	return inserted, nil
+}
+
+type rowGenerator struct {
+	r          *rand.Rand
+	columns    []*columnGenerator
+	references []*referenceGenerator
+	// column indexes of the primary and unique keys, with the values taken
+	uniqueKeys [][]int
+	seen       []map[string]bool
+}
+
+// Draws the values of a column.
+type columnGenerator struct {
+	column ColumnSchema
+	kind   columnKind
+	// unique columns follow a sequence starting after it
+	unique    bool
+	sequence  int64
+	notNull   bool
+	reference bool
+	override  ValueGenerator
+}
+
+// Picks the parent key of a foreign key.
+type referenceGenerator struct {
+	indexes  []int
+	keys     [][]interface{}
+	nullable bool
+}
+
+func newRowGenerator(db *sql.DB, o *options, table TableSchema, dbType DatabaseType) (*rowGenerator, error) {
+	columns := table.Columns
+	if len(o.columns) != 0 {
+		byName := make(map[string]ColumnSchema, len(table.Columns))
+		for _, column := range table.Columns {
+			byName[column.Name] = column
+		}
+
+		columns = nil
+		var unknown []string
+		for _, name := range o.columns {
+			column, ok := byName[name]
+			if !ok {
+				unknown = append(unknown, name)
+				continue
+			}
+			columns = append(columns, column)
+		}
+		if len(unknown) != 0 {
+			return nil, &UnknownColumnsError{Table: table.Name, Columns: unknown}
+		}
+	}
+
+	indexes := make(map[string]int, len(columns))
+	for i, column := range columns {
+		indexes[column.Name] = i
+	}
+
+	var unknown []string
+	for name := range o.generators {
+		if _, ok := indexes[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) != 0 {
+		return nil, &UnknownColumnsError{Table: table.Name, Columns: unknown}
+	}
+
+	seed := o.seed
+	if !o.seeded {
+		seed = time.Now().UnixNano()
+	}
+	g := &rowGenerator{r: rand.New(rand.NewSource(seed))}
+
+	isPrimaryKey := make(map[string]bool, len(table.PrimaryKeys))
+	for _, key := range table.PrimaryKeys {
+		isPrimaryKey[key] = true
+	}
+
+	g.columns = make([]*columnGenerator, len(columns))
+	for i, column := range columns {
+		g.columns[i] = &columnGenerator{
+			column:   column,
+			kind:     columnSchemaKind(column),
+			notNull:  !column.Nullable || isPrimaryKey[column.Name],
+			override: o.generators[column.Name],
+		}
+	}
+
+	uniqueKeys, err := getUniqueKeys(db, o, table.Name, dbType)
+	if err != nil {
+		return nil, err
+	}
+	if len(table.PrimaryKeys) != 0 {
+		uniqueKeys = append([][]string{table.PrimaryKeys}, uniqueKeys...)
+	}
+
+	for _, key := range uniqueKeys {
+		keyIndexes := make([]int, 0, len(key))
+		for _, name := range key {
+			if index, ok := indexes[name]; ok {
+				keyIndexes = append(keyIndexes, index)
+			}
+		}
+		// keys with columns left to their defaults cannot be checked
+		if len(keyIndexes) != len(key) {
+			continue
+		}
+
+		seen := map[string]bool{}
+		g.uniqueKeys = append(g.uniqueKeys, keyIndexes)
+		g.seen = append(g.seen, seen)
+
+		if len(keyIndexes) == 1 {
+			column := g.columns[keyIndexes[0]]
+			column.unique = true
+			if column.sequence, err = getSequenceStart(db, o, table.Name, column, dbType); err != nil {
+				return nil, err
+			}
+
+			switch column.kind {
+			case kindInteger, kindFloat, kindDecimal:
+				// the sequence starts after the largest value already
+				continue
+			}
+		}
+
+		// the other keys may collide with the rows of the table
+		if err := loadUniqueKeys(db, o, table.Name, key, dbType, seen); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, foreignKey := range table.ForeignKeys {
+		reference := &referenceGenerator{nullable: true}
+		for _, name := range foreignKey.Columns {
+			index, ok := indexes[name]
+			if !ok || g.columns[index].override != nil || g.columns[index].reference {
+				reference = nil
+				break
+			}
+			reference.indexes = append(reference.indexes, index)
+			reference.nullable = reference.nullable && !g.columns[index].notNull
+		}
+		if reference == nil {
+			continue
+		}
+
+		reference.keys, err = getParentKeys(db, o, foreignKey, dbType)
+		if err != nil {
+			return nil, fmt.Errorf("foreign key %s: %w", foreignKey.Name, err)
+		}
+		if len(reference.keys) == 0 && !reference.nullable {
+			return nil, fmt.Errorf("foreign key %s: table %s has no rows to reference", foreignKey.Name, foreignKey.ReferencedTable)
+		}
+
+		for _, index := range reference.indexes {
+			g.columns[index].reference = true
+		}
+		g.references = append(g.references, reference)
+	}
+
+	return g, nil
+}
+
+// Returns the unique constraints and indexes of the table other than the
+// primary key.
+func getUniqueKeys(db *sql.DB, o *options, tableName string, dbType DatabaseType) ([][]string, error) {
+	query, err := getQueryForUniqueKeys(dbType)
+	if err != nil {
+		return nil, fmt.Errorf("grabbing db type specific query: %w", err)
+	}
+
+	rows, err := queryRows(db, o, query, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("unique keys: %w", classifyError(err))
+	}
+	defer rows.Close()
+
+	var keys [][]string
+	var previous string
+	for rows.Next() {
+		var name, column string
+		if err := rows.Scan(&name, &column); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		if len(keys) == 0 || name != previous {
+			keys = append(keys, nil)
+			previous = name
+		}
+		keys[len(keys)-1] = append(keys[len(keys)-1], column)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Unique numbers continue after the largest one of the column, other unique
+// values after the number of rows.
+func getSequenceStart(db *sql.DB, o *options, tableName string, column *columnGenerator, dbType DatabaseType) (int64, error) {
+	quotedColumn, err := quoteIdentifier(column.column.Name, dbType)
+	if err != nil {
+		return 0, err
+	}
+
+	expression := "COUNT(*)"
+	switch column.kind {
+	case kindInteger, kindFloat, kindDecimal:
+		expression = fmt.Sprintf("MAX(%s)", quotedColumn)
+	}
+
+	query, err := getQueryForSelectColumns(tableName, []string{expression}, dbType)
+	if err != nil {
+		return 0, fmt.Errorf("grabbing db type specific query: %w", err)
+	}
+
+	var start interface{}
+	if err := queryRow(db, o, []interface{}{&start}, query); err != nil {
+		return 0, fmt.Errorf("column %s: %w", column.column.Name, classifyError(err))
+	}
+
+	sequence, err := parseSequenceStart(start)
+	if err != nil {
+		return 0, fmt.Errorf("column %s: %w", column.column.Name, err)
+	}
+	return sequence, nil
+}
+
+// Reads the largest value of a column as an integer, rounded down. Integers
+// and decimals are parsed from their text so that keys above 2^53 stay exact.
+func parseSequenceStart(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return v, nil
+	case float64:
+		return int64(math.Floor(v)), nil
+	}
+
+	text, ok := textValue(value)
+	if !ok {
+		text = fmt.Sprint(value)
+	}
+	text = strings.TrimSpace(text)
+
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return i, nil
+	}
+	// decimals: the integer part, one less for negative fractions
+	if whole, fraction, found := strings.Cut(text, "."); found {
+		i, err := strconv.ParseInt(whole, 10, 64)
+		if err == nil {
+			if strings.HasPrefix(whole, "-") && strings.Trim(fraction, "0") != "" {
+				i--
+			}
+			return i, nil
+		}
+	}
+
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("reading the largest value %q: %w", text, err)
+	}
+	return int64(math.Floor(f)), nil
+}
+
+// Reads the referenced keys of the parent rows, up to maxParentKeys of them.
+func getParentKeys(db *sql.DB, o *options, foreignKey ForeignKey, dbType DatabaseType) ([][]interface{}, error) {
+	quotedColumns, err := quoteIdentifiers(foreignKey.ReferencedColumns, dbType)
+	if err != nil {
+		return nil, err
+	}
+	query, err := getQueryForSelectColumns(foreignKey.ReferencedTable, quotedColumns, dbType)
+	if err != nil {
+		return nil, fmt.Errorf("grabbing db type specific query: %w", err)
+	}
+	query, err = getQueryForPage(query, quotedColumns, maxParentKeys, dbType)
+	if err != nil {
+		return nil, fmt.Errorf("grabbing db type specific query: %w", err)
+	}
+
+	rows, err := queryRows(db, o, query)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+
+	var keys [][]interface{}
+	for rows.Next() {
+		key := make([]interface{}, len(quotedColumns))
+		keyPtrs := make([]interface{}, len(key))
+		for i := range key {
+			keyPtrs[i] = &key[i]
+		}
+		if err := rows.Scan(keyPtrs...); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+
+	return keys, nil
+}
+
+// The decoding of the keys already in the table, to be compared with the
+// values drawn.
+var generateValueCodec = &ValueCodec{
+	DecimalAsString: true,
+	CanonicalUUID:   true,
+}
+
+// Marks the values of the unique key taken by the rows of the table as seen.
+func loadUniqueKeys(db *sql.DB, o *options, tableName string, key []string, dbType DatabaseType, seen map[string]bool) error {
+	quotedColumns, err := quoteIdentifiers(key, dbType)
+	if err != nil {
+		return err
+	}
+	query, err := getQueryForSelectColumns(tableName, quotedColumns, dbType)
+	if err != nil {
+		return fmt.Errorf("grabbing db type specific query: %w", err)
+	}
+
+	rows, err := queryRows(db, o, query)
+	if err != nil {
+		return fmt.Errorf("unique key %s: %w", strings.Join(key, ", "), classifyError(err))
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return fmt.Errorf("retrieving column types: %w", err)
+	}
+	decodings := getColumnDecodings(columnTypes)
+
+	values := make([]interface{}, len(key))
+	valuePtrs := make([]interface{}, len(key))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return fmt.Errorf("scanning row: %w", err)
+		}
+		for i := range values {
+			if values[i], err = decodeColumnValue(values[i], decodings[i], generateValueCodec, o); err != nil {
+				return fmt.Errorf("column %s: %w", key[i], err)
+			}
+		}
+		if tuple, ok := uniqueKeyTuple(values); ok {
+			seen[tuple] = true
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration: %w", err)
+	}
+	return nil
+}
+
+// Joins the values of a unique key into a text comparable between the values
+// drawn and those read back. ok is false when a value is NULL.
+func uniqueKeyTuple(values []interface{}) (string, bool) {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case nil:
+			return "", false
+		case []byte:
+			parts[i] = string(v)
+		case time.Time:
+			parts[i] = v.UTC().Format(time.RFC3339Nano)
+		case float64:
+			parts[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			parts[i] = fmt.Sprint(v)
+		}
+	}
+	return strings.Join(parts, "\x00"), true
+}
+
+// Draws the values of the row at the index until they are distinct from the
+// rows drawn before on every unique key.
+func (g *rowGenerator) row(row int) ([]interface{}, error) {
+	values := make([]interface{}, len(g.columns))
+
+	skips := 0
+	for attempt := 0; attempt < maxGenerateAttempts; attempt++ {
+		for i, column := range g.columns {
+			if column.reference {
+				continue
+			}
+			value, err := column.value(g.r, row)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", column.column.Name, err)
+			}
+			values[i] = value
+		}
+
+		for _, reference := range g.references {
+			var key []interface{}
+			if len(reference.keys) != 0 && !(reference.nullable && g.r.Intn(10) == 0) {
+				key = reference.keys[g.r.Intn(len(reference.keys))]
+			}
+			for j, index := range reference.indexes {
+				values[index] = nil
+				if key != nil {
+					values[index] = key[j]
+				}
+			}
+		}
+
+		collided := g.claimUniqueKeys(values)
+		if collided < 0 {
+			return values, nil
+		}
+
+		// a sequence reaching a value already taken moves on without using an
+		// attempt, at most once per value taken
+		if skips < len(g.seen[collided]) && g.advanceSequences(g.uniqueKeys[collided]) {
+			skips++
+			attempt--
+		}
+	}
+
+	return nil, fmt.Errorf("no unique values for row %d after %d attempts", row+1, maxGenerateAttempts)
+}
+
+// Moves the sequences of the columns of the key to their next value, false
+// when no column of the key follows a sequence.
+func (g *rowGenerator) advanceSequences(key []int) bool {
+	advanced := false
+	for _, index := range key {
+		if column := g.columns[index]; column.unique && column.override == nil {
+			column.sequence++
+			advanced = true
+		}
+	}
+	return advanced
+}
+
+// Records the unique keys of the values, unless one of them was drawn
+// before, in which case it returns the index of that key and -1 otherwise.
+// Keys with a NULL never collide.
+func (g *rowGenerator) claimUniqueKeys(values []interface{}) int {
+	tuples := make([]string, len(g.uniqueKeys))
+	for i, key := range g.uniqueKeys {
+		keyValues := make([]interface{}, len(key))
+		for j, index := range key {
+			keyValues[j] = values[index]
+		}
+
+		tuple, ok := uniqueKeyTuple(keyValues)
+		if !ok {
+			continue
+		}
+
+		tuples[i] = tuple
+		if g.seen[i][tuple] {
+			return i
+		}
+	}
+
+	for i, tuple := range tuples {
+		if tuple != "" {
+			g.seen[i][tuple] = true
+		}
+	}
+	return -1
+}
+
+func (c *columnGenerator) value(r *rand.Rand, row int) (interface{}, error) {
+	if c.override != nil {
+		return c.override(r, row)
+	}
+
+	if !c.notNull && !c.unique && r.Intn(10) == 0 {
+		return nil, nil
+	}
+
+	next := c.sequence + int64(row) + 1
+
+	// ENUM and SET columns take one of their values
+	if len(c.column.Values) != 0 {
+		if c.unique {
+			return c.column.Values[next%int64(len(c.column.Values))], nil
+		}
+		return c.column.Values[r.Intn(len(c.column.Values))], nil
+	}
+
+	switch c.kind {
+	case kindInteger:
+		if c.unique {
+			return next, nil
+		}
+		return r.Int63n(integerBound(c.column)), nil
+	case kindFloat:
+		if c.unique {
+			return float64(next), nil
+		}
+		return math.Round(r.Float64()*100000) / 100, nil
+	case kindDecimal:
+		if c.unique {
+			return strconv.FormatInt(next, 10), nil
+		}
+		return randomDecimal(r, c.column), nil
+	case kindBool, kindBit:
+		return r.Intn(2) == 1, nil
+	case kindTime:
+		return randomTime(r, c.column, c.unique, next), nil
+	case kindJSON:
+		return map[string]interface{}{"id": next, "value": words[r.Intn(len(words))]}, nil
+	case kindUUID:
+		b := make([]byte, 16)
+		binary.BigEndian.PutUint64(b, r.Uint64())
+		binary.BigEndian.PutUint64(b[8:], r.Uint64())
+		// version 4, RFC 4122 variant
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		return formatUUID(b), nil
+	case kindBinary:
+		size := 16
+		if c.column.Length > 0 && c.column.Length < size {
+			size = c.column.Length
+		}
+		b := make([]byte, 16)
+		if c.unique {
+			binary.BigEndian.PutUint64(b[8:], uint64(next))
+		} else {
+			binary.BigEndian.PutUint64(b, r.Uint64())
+			binary.BigEndian.PutUint64(b[8:], r.Uint64())
+		}
+		return b[16-size:], nil
+	default:
+		return randomText(r, c.column, c.unique, next), nil
+	}
+}
+
+// Bounds the integers drawn by the size of the type.
+func integerBound(column ColumnSchema) int64 {
+	switch {
+	case column.Type == "TINYINT":
+		return 128
+	case column.Type == "SMALLINT" || column.Type == "INT2":
+		return 32768
+	case column.Precision > 0 && column.Precision < 6:
+		return int64(math.Pow10(column.Precision))
+	default:
+		return 1000000
+	}
+}
+
+// Draws a decimal that fits the precision and scale of the column, as text.
+func randomDecimal(r *rand.Rand, column ColumnSchema) string {
+	precision, scale := column.Precision, column.Scale
+	if precision == 0 {
+		precision, scale = 8, 2
+	}
+
+	digits := precision - scale
+	if digits > 6 {
+		digits = 6
+	}
+	if scale > 6 {
+		scale = 6
+	}
+
+	text := "0"
+	if digits > 0 {
+		text = strconv.FormatInt(r.Int63n(int64(math.Pow10(digits))), 10)
+	}
+	if scale > 0 {
+		text += fmt.Sprintf(".%0*d", scale, r.Int63n(int64(math.Pow10(scale))))
+	}
+	return text
+}
+
+// Draws a time within five years of generatedTimeBase, dates without time of
+// day and times of day as text.
+func randomTime(r *rand.Rand, column ColumnSchema, unique bool, next int64) interface{} {
+	switch column.Type {
+	case "DATE":
+		days := r.Int63n(5 * 365)
+		if unique {
+			days = next
+		}
+		return generatedTimeBase.AddDate(0, 0, int(days))
+	case "TIME", "TIMETZ":
+		seconds := r.Int63n(24 * 60 * 60)
+		if unique {
+			seconds = next % (24 * 60 * 60)
+		}
+		return generatedTimeBase.Add(time.Duration(seconds) * time.Second).Format("15:04:05")
+	default:
+		seconds := r.Int63n(5 * 365 * 24 * 60 * 60)
+		if unique {
+			seconds = next
+		}
+		return generatedTimeBase.Add(time.Duration(seconds) * time.Second)
+	}
+}
+
+// Draws text after the name of the column, cut to its length. Unique text
+// ends with the sequence number.
+func randomText(r *rand.Rand, column ColumnSchema, unique bool, next int64) string {
+	name := strings.ToLower(column.Name)
+	first := firstNames[r.Intn(len(firstNames))]
+	last := lastNames[r.Intn(len(lastNames))]
+
+	// kept whole when the text is cut
+	suffix := ""
+	if unique {
+		suffix = "-" + strconv.FormatInt(next, 10)
+	}
+
+	var text string
+	switch {
+	case strings.Contains(name, "email"):
+		text = strings.ToLower(first + "." + last)
+		suffix = strings.TrimPrefix(suffix, "-") + "@example.com"
+	case strings.Contains(name, "first"):
+		text = first
+	case strings.Contains(name, "last") || strings.Contains(name, "surname"):
+		text = last
+	case strings.Contains(name, "name"):
+		text = first + " " + last
+	case strings.Contains(name, "phone"):
+		text = fmt.Sprintf("+1-555-%03d-%04d", r.Intn(1000), r.Intn(10000))
+	case strings.Contains(name, "city"):
+		text = cities[r.Intn(len(cities))]
+	case strings.Contains(name, "country"):
+		text = countries[r.Intn(len(countries))]
+	case strings.Contains(name, "address") || strings.Contains(name, "street"):
+		text = fmt.Sprintf("%d %s Street", r.Intn(999)+1, last)
+	case strings.Contains(name, "zip") || strings.Contains(name, "postal"):
+		text = fmt.Sprintf("%05d", r.Intn(100000))
+	case strings.Contains(name, "url") || strings.Contains(name, "website"):
+		text = "https://www.example.com/" + words[r.Intn(len(words))]
+	default:
+		parts := make([]string, r.Intn(4)+1)
+		for i := range parts {
+			parts[i] = words[r.Intn(len(words))]
+		}
+		text = strings.Join(parts, " ")
+	}
+
+	if column.Length > 0 && len(text)+len(suffix) > column.Length {
+		if len(suffix) >= column.Length {
+			return suffix[len(suffix)-column.Length:]
+		}
+		text = text[:column.Length-len(suffix)]
+	}
+	return text + suffix
+}
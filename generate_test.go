@@ -0,0 +1,136 @@
+package sqlutils
+
+import (
+	"database/sql"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func readAllRows(t *testing.T, db *sql.DB, query string) [][]interface{} {
+	t.Helper()
+
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all [][]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, values)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return all
+}
+
+func TestGenerateRowsWithSeed(t *testing.T) {
+	schema := `CREATE TABLE people (
+		id INTEGER PRIMARY KEY,
+		email VARCHAR(40) NOT NULL UNIQUE,
+		name VARCHAR(30),
+		city TEXT,
+		born DATE,
+		balance NUMERIC(8, 2)
+	)`
+	dbA := openTestDB(t, schema)
+	dbB := openTestDB(t, schema)
+
+	for _, db := range []*sql.DB{dbA, dbB} {
+		inserted, err := GenerateRows(db, "people", SQLite, 50, WithSeed(42))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inserted != 50 {
+			t.Fatalf("GenerateRows inserted %d rows, want 50", inserted)
+		}
+	}
+
+	query := "SELECT id, email, name, city, born, balance FROM people ORDER BY id"
+	rowsA, rowsB := readAllRows(t, dbA, query), readAllRows(t, dbB, query)
+	if !reflect.DeepEqual(rowsA, rowsB) {
+		t.Fatal("GenerateRows drew different rows with the same seed")
+	}
+
+	var distinct int64
+	if err := dbA.QueryRow("SELECT COUNT(DISTINCT email) FROM people").Scan(&distinct); err != nil {
+		t.Fatal(err)
+	}
+	if distinct != 50 {
+		t.Fatalf("the unique column has %d distinct values, want 50", distinct)
+	}
+	for _, row := range rowsA {
+		if email, _ := row[1].(string); len(email) > 40 {
+			t.Fatalf("email %q is longer than its column", email)
+		}
+	}
+}
+
+func TestGenerateRowsSkipsTakenUniqueValues(t *testing.T) {
+	db := openTestDB(t, "CREATE TABLE days (day DATE PRIMARY KEY)")
+
+	if _, err := GenerateRows(db, "days", SQLite, 3, WithSeed(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("DELETE FROM days WHERE day = (SELECT MIN(day) FROM days)"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the row count now points at a day still in the table
+	if _, err := GenerateRows(db, "days", SQLite, 3, WithSeed(1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := countRows(t, db, "days"); got != 5 {
+		t.Fatalf("table has %d rows, want 5", got)
+	}
+}
+
+func TestGenerateRowsUniqueTextCutToItsLength(t *testing.T) {
+	db := openTestDB(t,
+		"CREATE TABLE codes (id INTEGER PRIMARY KEY, code VARCHAR(2) NOT NULL UNIQUE)",
+		"INSERT INTO codes (id, code) VALUES (1, '-5'), (2, '-6'), (3, '-7'), (4, '-8')",
+	)
+
+	// unique text ends with the sequence after the row count, here taken
+	if _, err := GenerateRows(db, "codes", SQLite, 20, WithSeed(7)); err != nil {
+		t.Fatal(err)
+	}
+	if got := countRows(t, db, "codes"); got != 24 {
+		t.Fatalf("table has %d rows, want 24", got)
+	}
+}
+
+func TestColumnGeneratorDrawsEnumValues(t *testing.T) {
+	values := []string{"small", "medium", "large"}
+	column := &columnGenerator{
+		column:  ColumnSchema{Name: "size", Type: "ENUM", Values: values},
+		kind:    columnSchemaKind(ColumnSchema{Name: "size", Type: "ENUM"}),
+		notNull: true,
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for row := 0; row < 20; row++ {
+		value, err := column.value(r, row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, _ := value.(string)
+		if text != values[0] && text != values[1] && text != values[2] {
+			t.Fatalf("ENUM column got %v, want one of %v", value, values)
+		}
+	}
+}
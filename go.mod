@@ -2,7 +2,10 @@ module github.com/sgsavu/sqlutils/v4
 
 go 1.22.5
 
-require github.com/go-sql-driver/mysql v1.8.1
+require (
+	github.com/go-sql-driver/mysql v1.8.1
+	golang.org/x/exp v0.0.0-20240808152545-0cdaa3abc0fa
+)
 
 require (
 	github.com/go-logfmt/logfmt v0.6.0 // indirect
@@ -10,7 +13,6 @@ require (
 	github.com/golang-sql/civil v0.0.0-20220223132316-b832511892a9 // indirect
 	github.com/golang-sql/sqlexp v0.1.0 // indirect
 	golang.org/x/crypto v0.26.0 // indirect
-	golang.org/x/exp v0.0.0-20240808152545-0cdaa3abc0fa // indirect
 	google.golang.org/protobuf v1.34.2 // indirect
 )
 
@@ -0,0 +1,137 @@
+package sqlutils
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// Guard restricts which helpers may run against a connection.
+type Guard struct {
+	// ReadOnly rejects every operation that changes data or schema.
+	ReadOnly bool
+	// AllowedTables limits the operations to these tables, all tables are
+	// allowed when it is empty.
+	AllowedTables []string
+	// DeniedOperations are rejected regardless of the table.
+	DeniedOperations []Operation
+}
+
+// GuardError is returned when a guard rejects an operation, it matches
+// ErrOperationDenied.
+type GuardError struct {
+	Operation Operation
+	Table     string
+	Reason    string
+}
+
+func (e *GuardError) Error() string {
+	if e.Table == "" {
+		return fmt.Sprintf("%s denied: %s", e.Operation, e.Reason)
+	}
+	return fmt.Sprintf("%s on %s denied: %s", e.Operation, e.Table, e.Reason)
+}
+
+func (e *GuardError) Unwrap() error {
+	return ErrOperationDenied
+}
+
+// Check returns a GuardError when the guard does not allow the operation on
+// the given tables.
+func (g *Guard) Check(operation Operation, tableNames ...string) error {
+	if g == nil {
+		return nil
+	}
+
+	if g.ReadOnly && mutatingOperations[operation] {
+		return &GuardError{Operation: operation, Reason: "connection is read-only"}
+	}
+
+	for _, denied := range g.DeniedOperations {
+		if denied == operation {
+			return &GuardError{Operation: operation, Reason: "operation is not allowed"}
+		}
+	}
+
+	if len(g.AllowedTables) == 0 {
+		return nil
+	}
+
+	for _, tableName := range tableNames {
+		allowed := false
+		for _, allowedTable := range g.AllowedTables {
+			if allowedTable == tableName {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &GuardError{Operation: operation, Table: tableName, Reason: "table is not allowed"}
+		}
+	}
+
+	return nil
+}
+
+// Guards registered per connection with SetGuard or ConnectGuardedDB.
+var guards sync.Map
+
+// SetGuard applies the guard to every call made with the connection, a nil
+// guard removes it. Guards passed with WithGuard apply on top of it.
+func SetGuard(db *sql.DB, guard *Guard) {
+	if guard == nil {
+		guards.Delete(db)
+		return
+	}
+	guards.Store(db, guard)
+}
+
+// ConnectGuardedDB connects like ConnectDB and registers the guard for the
+// connection. A read-only guard also opens the connection in read-only mode
+// where the driver supports it (SQLite, PostgreSQL, CockroachDB and MySQL).
+func ConnectGuardedDB(connInfo *DBConnection, guard *Guard) (*sql.DB, error) {
+	db, err := connectDB(connInfo, guard != nil && guard.ReadOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	SetGuard(db, guard)
+
+	return db, nil
+}
+
+// Checks the operation of the call against the guard of the connection and
+// the one given with WithGuard.
+func checkGuard(o *options, tableNames ...string) error {
+	if o.skipGuard {
+		return nil
+	}
+
+	if guard, ok := guards.Load(o.db); ok {
+		if err := guard.(*Guard).Check(o.operation, tableNames...); err != nil {
+			return err
+		}
+	}
+
+	return o.guard.Check(o.operation, tableNames...)
+}
+
+// Keeps the tables the guards allow the operation on, for the helpers
+// listing tables.
+func filterAllowedTables(o *options, tableNames []string) []string {
+	allowed := make([]string, 0, len(tableNames))
+	for _, tableName := range tableNames {
+		if checkGuard(o, tableName) == nil {
+			allowed = append(allowed, tableName)
+		}
+	}
+	return allowed
+}
+
+// Lets a helper called by another one run the statements the outer call was
+// allowed to.
+func withoutGuard() Option {
+	return func(o *options) {
+		o.skipGuard = true
+	}
+}
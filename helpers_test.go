@@ -0,0 +1,71 @@
+package sqlutils
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Opens a new SQLite database running the statements, closed at the end of
+// the test.
+func openTestDB(t *testing.T, statements ...string) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { CloseDB(db) })
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+	return db
+}
+
+func countRows(t *testing.T, db *sql.DB, tableName string) int64 {
+	t.Helper()
+
+	var count int64
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + tableName).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+// Hook keeping the statements the helpers run.
+type statementRecorder struct {
+	mutex      sync.Mutex
+	statements []string
+}
+
+func (r *statementRecorder) BeforeQuery(ctx context.Context, _ *QueryEvent) context.Context {
+	return ctx
+}
+
+func (r *statementRecorder) AfterQuery(_ context.Context, event *QueryEvent) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.statements = append(r.statements, event.SQL)
+}
+
+// Counts the statements starting with the prefix, case insensitively.
+func (r *statementRecorder) count(prefix string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	count := 0
+	for _, statement := range r.statements {
+		if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(statement)), strings.ToUpper(prefix)) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,121 @@
+package sqlutils
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// QueryEvent describes a statement issued by one of the helpers.
+type QueryEvent struct {
+	Operation Operation
+	Table     string
+	SQL       string
+	Args      []interface{}
+	StartedAt time.Time
+	// Duration, RowsAffected and Err are only set in AfterQuery. RowsAffected
+	// is -1 for queries returning rows and when the driver does not report it.
+	Duration     time.Duration
+	RowsAffected int64
+	Err          error
+}
+
+// Hook is notified around every statement the helpers run. The context
+// returned by BeforeQuery is the one given to AfterQuery, which allows hooks
+// to carry state such as spans between the two.
+type Hook interface {
+	BeforeQuery(ctx context.Context, event *QueryEvent) context.Context
+	AfterQuery(ctx context.Context, event *QueryEvent)
+}
+
+var (
+	hooksMutex  sync.RWMutex
+	globalHooks []Hook
+	connHooks   = map[*sql.DB][]Hook{}
+)
+
+// RegisterHook adds a hook notified for the statements of every connection.
+func RegisterHook(hook Hook) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	globalHooks = append(globalHooks, hook)
+}
+
+// RegisterConnHook adds a hook notified for the statements of the connection.
+func RegisterConnHook(db *sql.DB, hook Hook) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	connHooks[db] = append(connHooks[db], hook)
+}
+
+// ClearHooks removes the hooks registered for the connection, or the global
+// ones when db is nil.
+func ClearHooks(db *sql.DB) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	if db == nil {
+		globalHooks = nil
+		return
+	}
+	delete(connHooks, db)
+}
+
+// WithHooks adds hooks notified for the statements of this call only.
+func WithHooks(hooks ...Hook) Option {
+	return func(o *options) {
+		o.hooks = append(o.hooks, hooks...)
+	}
+}
+
+// Global hooks first, then the connection ones, then the ones of the call.
+func collectHooks(o *options) []Hook {
+	hooksMutex.RLock()
+	defer hooksMutex.RUnlock()
+
+	hooks := make([]Hook, 0, len(globalHooks)+len(connHooks[o.db])+len(o.hooks))
+	hooks = append(hooks, globalHooks...)
+	hooks = append(hooks, connHooks[o.db]...)
+	hooks = append(hooks, o.hooks...)
+
+	return hooks
+}
+
+// Runs fn between the BeforeQuery and AfterQuery calls of the hooks.
+func runWithHooks(o *options, query string, args []interface{}, fn func(ctx context.Context) (int64, error)) error {
+	ctx, finish := startHooks(o, query, args)
+	rowsAffected, err := fn(ctx)
+	finish(rowsAffected, err)
+	return err
+}
+
+// Calls BeforeQuery of the hooks and returns the context of the statement
+// along with the function calling AfterQuery once it is over.
+func startHooks(o *options, query string, args []interface{}) (context.Context, func(rowsAffected int64, err error)) {
+	hooks := collectHooks(o)
+	if len(hooks) == 0 {
+		return o.ctx, func(int64, error) {}
+	}
+
+	event := &QueryEvent{
+		Operation: o.operation,
+		Table:     o.table,
+		SQL:       query,
+		Args:      args,
+		StartedAt: time.Now(),
+	}
+
+	ctx := o.ctx
+	for _, hook := range hooks {
+		ctx = hook.BeforeQuery(ctx, event)
+	}
+
+	return ctx, func(rowsAffected int64, err error) {
+		event.RowsAffected, event.Err = rowsAffected, err
+		event.Duration = time.Since(event.StartedAt)
+
+		for index := len(hooks) - 1; index >= 0; index-- {
+			hooks[index].AfterQuery(ctx, event)
+		}
+	}
+}
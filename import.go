@@ -0,0 +1,736 @@
+package sqlutils
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportMode decides how ImportTable writes the records.
+type ImportMode string
+
+const (
+	// ImportInsert inserts the records, those whose primary key is already
+	// in the table fail.
+	ImportInsert ImportMode = "insert"
+	// ImportUpsert inserts the records, or updates the columns given of the
+	// row with the same primary key.
+	ImportUpsert ImportMode = "upsert"
+	// ImportReplace deletes the row with the same primary key before
+	// inserting the record, the columns not given take their defaults.
+	ImportReplace ImportMode = "replace"
+)
+
+// The number of records written per transaction without WithBatchSize.
+const defaultBatchSize = 500
+
+// WithImportMode writes the imported records with the mode, ImportInsert by
+// default.
+func WithImportMode(mode ImportMode) Option {
+	return func(o *options) {
+		o.importMode = mode
+	}
+}
+
+// WithColumnMapping maps the columns of the input to the columns of the
+// table, input columns missing from the mapping keep their name and those
+// mapped to "" are left out.
+func WithColumnMapping(mapping map[string]string) Option {
+	return func(o *options) {
+		o.columnMapping = mapping
+	}
+}
+
+// WithBatchSize writes the records in transactions of the size.
+func WithBatchSize(size int) Option {
+	return func(o *options) {
+		o.batchSize = size
+	}
+}
+
+// ImportReport is the outcome of ImportTable.
+type ImportReport struct {
+	// Inserted counts the records written, updated and replaced rows
+	// included.
+	Inserted int64
+	Skipped  []ImportIssue
+	Failed   []ImportIssue
+}
+
+// ImportIssue is a record of the input ImportTable did not write.
+type ImportIssue struct {
+	// Line is the line of the input the record starts on.
+	Line   int
+	Reason string
+}
+
+// ImportTable reads records in the format from r and writes them to the
+// table, the reverse of ExportTable. The input columns are checked against the
+// columns of the table, unknown ones failing the import or, with
+// WithLenientColumns, left out, and the values are converted to the types of
+// the columns. Records are written in transactions of WithBatchSize records,
+// a batch that fails is retried one record at a time so that only the failing
+// records are reported. Records with no values are skipped.
+//
+// CSV files start with a header unless WithoutCSVHeader is given, the fields
+// being then the columns of WithColumns or of the table in order. Fields
+// equal to the WithNullString value are NULL and binary columns are base64
+// encoded, as ExportTable writes them.
+func ImportTable(
+	db *sql.DB,
+	tableName string,
+	dbType DatabaseType,
+	format DataFormat,
+	r io.Reader,
+	opts ...Option,
+) (ImportReport, error) {
+	o := newOptions(db, OpImportTable, tableName, opts)
+
+	if err := checkGuard(o, tableName); err != nil {
+		return ImportReport{}, fmt.Errorf("ImportTable - %w", err)
+	}
+
+	importer, err := newTableImporter(db, o, tableName, dbType)
+	if err != nil {
+		return ImportReport{}, fmt.Errorf("ImportTable - %w", err)
+	}
+
+	switch format {
+	case FormatCSV:
+		err = importer.readCSV(r)
+	case FormatJSON:
+		err = importer.readJSON(r)
+	case FormatNDJSON:
+		err = importer.readNDJSON(r)
+	default:
+		return ImportReport{}, fmt.Errorf("ImportTable - unsupported format %q", format)
+	}
+	if err == nil {
+		err = importer.flush()
+	}
+	if err != nil {
+		return importer.report, fmt.Errorf("ImportTable - %w", err)
+	}
+
+	err = writeAudit(o, AuditEntry{Details: map[string]interface{}{
+		"format":   format,
+		"mode":     importer.mode,
+		"inserted": importer.report.Inserted,
+		"skipped":  len(importer.report.Skipped),
+		"failed":   len(importer.report.Failed),
+	}})
+	if err != nil {
+		return importer.report, fmt.Errorf("ImportTable - %w", err)
+	}
+
+	return importer.report, nil
+}
+
+type importRecord struct {
+	line   int
+	record TableRecord
+}
+
+type tableImporter struct {
+	db          *sql.DB
+	o           *options
+	tableName   string
+	dbType      DatabaseType
+	mode        ImportMode
+	batchSize   int
+	decodings   map[string]columnDecoding
+	primaryKeys []string
+	pending     []importRecord
+	report      ImportReport
+}
+
+func newTableImporter(db *sql.DB, o *options, tableName string, dbType DatabaseType) (*tableImporter, error) {
+	importer, err := newImporterFor(db, o, tableName, dbType)
+	if err != nil {
+		return nil, err
+	}
+
+	if importer.mode != ImportInsert {
+		primaryKeys, err := GetPrimaryKeys(db, "", tableName, dbType, inherit(o))
+		if err != nil {
+			return nil, fmt.Errorf("grabbing primary keys: %w", err)
+		}
+		if err := importer.setPrimaryKeys(primaryKeys); err != nil {
+			return nil, err
+		}
+	}
+
+	decodings, err := getTableDecodings(db, o, tableName, dbType)
+	if err != nil {
+		return nil, err
+	}
+	importer.decodings = decodings
+
+	return importer, nil
+}
+
+// Returns an importer for the mode and batch size of the call, without the
+// column decodings and the primary keys.
+func newImporterFor(db *sql.DB, o *options, tableName string, dbType DatabaseType) (*tableImporter, error) {
+	importer := &tableImporter{
+		db:        db,
+		o:         o,
+		tableName: tableName,
+		dbType:    dbType,
+		mode:      o.importMode,
+		batchSize: o.batchSize,
+	}
+	if importer.mode == "" {
+		importer.mode = ImportInsert
+	}
+	if importer.batchSize <= 0 {
+		importer.batchSize = defaultBatchSize
+	}
+
+	switch importer.mode {
+	case ImportInsert, ImportUpsert, ImportReplace:
+		return importer, nil
+	default:
+		return nil, fmt.Errorf("unsupported import mode %q", importer.mode)
+	}
+}
+
+// Upserts and replacements find the existing rows by primary key.
+func (imp *tableImporter) setPrimaryKeys(primaryKeys []string) error {
+	if len(primaryKeys) == 0 {
+		return fmt.Errorf("%s mode needs a primary key on table %s", imp.mode, imp.tableName)
+	}
+	imp.primaryKeys = primaryKeys
+	return nil
+}
+
+// Returns the decoding of each column of the table, from the column types of
+// an empty result.
+func getTableDecodings(db *sql.DB, o *options, tableName string, dbType DatabaseType) (map[string]columnDecoding, error) {
+	query, err := getQueryForSelectColumns(tableName, []string{"*"}, dbType)
+	if err != nil {
+		return nil, fmt.Errorf("grabbing db type specific query: %w", err)
+	}
+
+	jsonColumns, err := getJSONColumns(db, o, tableName, dbType)
+	if err != nil {
+		return nil, fmt.Errorf("grabbing JSON columns: %w", err)
+	}
+
+	boolColumns, err := getBoolColumns(db, o, tableName, dbType)
+	if err != nil {
+		return nil, fmt.Errorf("grabbing boolean columns: %w", err)
+	}
+
+	rows, err := queryRows(db, o, query+" WHERE 1 = 0")
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", classifyError(err))
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("retrieving column types: %w", err)
+	}
+
+	decodings := make(map[string]columnDecoding, len(columnTypes))
+	for i, decoding := range getColumnDecodings(columnTypes) {
+		name := columnTypes[i].Name()
+		if jsonColumns[name] {
+			decoding.kind = kindJSON
+		}
+		if boolColumns[name] {
+			decoding.kind = kindBool
+		}
+		decodings[name] = decoding
+	}
+
+	return decodings, nil
+}
+
+// Maps the input columns to the columns of the table, "" for those left out.
+func (imp *tableImporter) targetColumns(inputColumns []string) ([]string, error) {
+	columns, err := getCachedColumns(imp.db, imp.o, imp.tableName, imp.dbType)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]string, len(inputColumns))
+	var unknown []string
+	for i, inputColumn := range inputColumns {
+		target := inputColumn
+		if mapped, ok := imp.o.columnMapping[inputColumn]; ok {
+			target = mapped
+		}
+		if target == "" {
+			continue
+		}
+
+		if !columns[target] {
+			if !imp.o.lenientColumns {
+				unknown = append(unknown, target)
+			}
+			continue
+		}
+		targets[i] = target
+	}
+
+	if len(unknown) != 0 {
+		sort.Strings(unknown)
+		return nil, &UnknownColumnsError{Table: imp.tableName, Columns: unknown}
+	}
+
+	return targets, nil
+}
+
+func (imp *tableImporter) readCSV(r io.Reader) error {
+	reader := csv.NewReader(r)
+	if imp.o.csvDelimiter != 0 {
+		reader.Comma = imp.o.csvDelimiter
+	}
+
+	var inputColumns []string
+	switch {
+	case !imp.o.csvNoHeader:
+		header, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading CSV header: %w", err)
+		}
+		if len(header) != 0 {
+			header[0] = strings.TrimPrefix(header[0], "\ufeff")
+		}
+		inputColumns = header
+	case len(imp.o.columns) != 0:
+		inputColumns = imp.o.columns
+	default:
+		columns, err := GetColumns(imp.db, imp.tableName, imp.dbType, inherit(imp.o))
+		if err != nil {
+			return err
+		}
+		inputColumns = columns
+	}
+	reader.FieldsPerRecord = len(inputColumns)
+
+	targets, err := imp.targetColumns(inputColumns)
+	if err != nil {
+		return err
+	}
+
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		var parseErr *csv.ParseError
+		if errors.Is(err, csv.ErrFieldCount) && errors.As(err, &parseErr) {
+			imp.report.Failed = append(imp.report.Failed, ImportIssue{
+				Line:   parseErr.StartLine,
+				Reason: fmt.Sprintf("expected %d fields, got %d", len(inputColumns), len(fields)),
+			})
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("reading CSV: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		record := make(TableRecord, len(fields))
+		var convertErr error
+		for i, field := range fields {
+			if targets[i] == "" {
+				continue
+			}
+			if field == imp.o.nullString {
+				record[targets[i]] = nil
+				continue
+			}
+
+			record[targets[i]], convertErr = convertImportText(field, imp.decodings[targets[i]], imp.o)
+			if convertErr != nil {
+				convertErr = fmt.Errorf("column %s: %w", targets[i], convertErr)
+				break
+			}
+		}
+
+		if err := imp.add(line, record, convertErr); err != nil {
+			return err
+		}
+	}
+}
+
+func (imp *tableImporter) readJSON(r io.Reader) error {
+	tracker := &lineTracker{r: r, line: 1}
+	decoder := json.NewDecoder(tracker)
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading JSON: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("reading JSON: expected an array of objects")
+	}
+
+	for decoder.More() {
+		line := tracker.lineAt(decoder.InputOffset())
+
+		var object map[string]interface{}
+		err := decoder.Decode(&object)
+
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			// the value was read, only it is not an object
+			imp.report.Failed = append(imp.report.Failed, ImportIssue{Line: line, Reason: "expected a JSON object"})
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("reading JSON: %w", err)
+		}
+
+		if err := imp.addObject(line, object); err != nil {
+			return err
+		}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("reading JSON: %w", err)
+	}
+
+	return nil
+}
+
+func (imp *tableImporter) readNDJSON(r io.Reader) error {
+	reader := bufio.NewReader(r)
+
+	for line := 1; ; line++ {
+		content, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return fmt.Errorf("reading NDJSON: %w", readErr)
+		}
+
+		content = bytes.TrimSpace(content)
+		if len(content) != 0 {
+			decoder := json.NewDecoder(bytes.NewReader(content))
+			decoder.UseNumber()
+
+			var object map[string]interface{}
+			if err := decoder.Decode(&object); err != nil {
+				imp.report.Failed = append(imp.report.Failed, ImportIssue{Line: line, Reason: err.Error()})
+			} else if err := imp.addObject(line, object); err != nil {
+				return err
+			}
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+func (imp *tableImporter) addObject(line int, object map[string]interface{}) error {
+	inputColumns := make([]string, 0, len(object))
+	for column := range object {
+		inputColumns = append(inputColumns, column)
+	}
+
+	targets, err := imp.targetColumns(inputColumns)
+	if err != nil {
+		imp.report.Failed = append(imp.report.Failed, ImportIssue{Line: line, Reason: err.Error()})
+		return nil
+	}
+
+	record := make(TableRecord, len(object))
+	var convertErr error
+	for i, inputColumn := range inputColumns {
+		if targets[i] == "" {
+			continue
+		}
+
+		record[targets[i]], convertErr = convertImportValue(object[inputColumn], imp.decodings[targets[i]], imp.o)
+		if convertErr != nil {
+			convertErr = fmt.Errorf("column %s: %w", targets[i], convertErr)
+			break
+		}
+	}
+
+	return imp.add(line, record, convertErr)
+}
+
+// Queues the record, writing the batch once full.
+func (imp *tableImporter) add(line int, record TableRecord, convertErr error) error {
+	if convertErr != nil {
+		imp.report.Failed = append(imp.report.Failed, ImportIssue{Line: line, Reason: convertErr.Error()})
+		return nil
+	}
+
+	empty := true
+	for _, value := range record {
+		if value != nil {
+			empty = false
+			break
+		}
+	}
+	if empty {
+		imp.report.Skipped = append(imp.report.Skipped, ImportIssue{Line: line, Reason: "no values"})
+		return nil
+	}
+
+	for _, key := range imp.primaryKeys {
+		if record[key] == nil {
+			imp.report.Failed = append(imp.report.Failed, ImportIssue{
+				Line:   line,
+				Reason: fmt.Sprintf("missing primary key column %s", key),
+			})
+			return nil
+		}
+	}
+
+	imp.pending = append(imp.pending, importRecord{line: line, record: record})
+	if len(imp.pending) >= imp.batchSize {
+		return imp.flush()
+	}
+
+	return nil
+}
+
+// Writes the pending records in one transaction, or one at a time when the
+// batch fails.
+func (imp *tableImporter) flush() error {
+	batch := imp.pending
+	imp.pending = nil
+	if len(batch) == 0 {
+		return nil
+	}
+
+	err := inTransaction(imp.db, imp.o, true, func(e execer) error {
+		for _, record := range batch {
+			if err := imp.write(e, record.record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		imp.report.Inserted += int64(len(batch))
+		return nil
+	}
+
+	for _, record := range batch {
+		if err := imp.o.ctx.Err(); err != nil {
+			return err
+		}
+
+		err := inTransaction(imp.db, imp.o, true, func(e execer) error {
+			return imp.write(e, record.record)
+		})
+		if err != nil {
+			imp.report.Failed = append(imp.report.Failed, ImportIssue{Line: record.line, Reason: classifyError(err).Error()})
+			continue
+		}
+		imp.report.Inserted++
+	}
+
+	return nil
+}
+
+func (imp *tableImporter) write(e execer, record TableRecord) error {
+	keys, values := extractRecordData(record)
+	values, err := encodeValues(imp.o, values)
+	if err != nil {
+		return err
+	}
+
+	if imp.mode == ImportUpsert {
+		query, err := getQueryForUpsert(imp.tableName, keys, imp.primaryKeys, imp.dbType)
+		if err != nil {
+			return err
+		}
+		_, err = execStatement(e, imp.o, query, values...)
+		return err
+	}
+
+	quotedTableName, err := quoteIdentifier(imp.tableName, imp.dbType)
+	if err != nil {
+		return err
+	}
+
+	if imp.mode == ImportReplace {
+		conditions, err := computeConditions(imp.primaryKeys, imp.dbType, 1)
+		if err != nil {
+			return err
+		}
+		primaryKeyValues := make([]interface{}, len(imp.primaryKeys))
+		for index, key := range imp.primaryKeys {
+			primaryKeyValues[index] = record[key]
+		}
+		primaryKeyValues, err = encodeValues(imp.o, primaryKeyValues)
+		if err != nil {
+			return err
+		}
+
+		query := fmt.Sprintf("DELETE FROM %s WHERE %s", quotedTableName, conditions)
+		if _, err := execStatement(e, imp.o, query, primaryKeyValues...); err != nil {
+			return err
+		}
+	}
+
+	quotedKeys, err := quoteIdentifiers(keys, imp.dbType)
+	if err != nil {
+		return err
+	}
+	placeholders, err := getPlaceholders(imp.dbType, 1, len(values))
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quotedTableName,
+		strings.Join(quotedKeys, ", "),
+		strings.Join(placeholders, ", "),
+	)
+	_, err = execStatement(e, imp.o, query, values...)
+	return err
+}
+
+// Converts a value decoded from JSON to the type of the column.
+func convertImportValue(value interface{}, column columnDecoding, o *options) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return convertImportText(v, column, o)
+	case json.Number:
+		return convertImportText(v.String(), column, o)
+	case bool:
+		switch column.kind {
+		case kindInteger:
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case kindText:
+			return strconv.FormatBool(v), nil
+		}
+		return v, nil
+	default:
+		// nil, and objects and arrays which are written as JSON
+		return value, nil
+	}
+}
+
+// Converts a text value to the type of the column. Times that do not parse
+// are handed to the database as they are.
+func convertImportText(text string, column columnDecoding, o *options) (interface{}, error) {
+	switch column.kind {
+	case kindInteger:
+		trimmed := strings.TrimSpace(text)
+		if i, err := strconv.ParseInt(trimmed, 10, 64); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(trimmed, 10, 64); err == nil {
+			return u, nil
+		}
+		return nil, fmt.Errorf("invalid integer %q", text)
+	case kindFloat:
+		f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", text)
+		}
+		return f, nil
+	case kindBool, kindBit:
+		b, err := strconv.ParseBool(strings.TrimSpace(text))
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean %q", text)
+		}
+		return b, nil
+	case kindBinary:
+		b, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64: %w", err)
+		}
+		return b, nil
+	case kindTime:
+		if t, ok := parseImportTime(text, getValueCodec(o)); ok {
+			return t, nil
+		}
+	}
+
+	return text, nil
+}
+
+var importTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// Parses the layout of the codec and the common layouts, times without zone
+// being in the location of the codec, UTC by default.
+func parseImportTime(text string, codec *ValueCodec) (time.Time, bool) {
+	location := time.UTC
+	if codec.Location != nil {
+		location = codec.Location
+	}
+
+	layouts := importTimeLayouts
+	if codec.TimeFormat != "" {
+		layouts = append([]string{codec.TimeFormat}, layouts...)
+	}
+
+	text = strings.TrimSpace(text)
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, text, location); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Keeps the bytes read by a JSON decoder from the last position asked on, to
+// tell the line a value starts on.
+type lineTracker struct {
+	r      io.Reader
+	buffer []byte
+	offset int64
+	line   int
+}
+
+func (t *lineTracker) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.buffer = append(t.buffer, p[:n]...)
+	return n, err
+}
+
+// Returns the line of the first byte from offset on that is not white space
+// or a comma, forgetting the bytes before it.
+func (t *lineTracker) lineAt(offset int64) int {
+	index := int(offset - t.offset)
+	for index < len(t.buffer) && strings.IndexByte(" \t\r\n,", t.buffer[index]) != -1 {
+		index++
+	}
+
+	t.line += bytes.Count(t.buffer[:index], []byte{'\n'})
+	t.buffer = append(t.buffer[:0], t.buffer[index:]...)
+	t.offset += int64(index)
+
+	return t.line
+}
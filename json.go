@@ -0,0 +1,200 @@
+package sqlutils
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Returns the columns of the table holding JSON in text columns, nil for the
+// dialects reporting JSON in the result column types.
+func getJSONColumns(db *sql.DB, o *options, tableName string, databaseType DatabaseType) (map[string]bool, error) {
+	query, ok := getQueryForJSONColumns(databaseType)
+	if !ok {
+		return nil, nil
+	}
+
+	cache := &getTableCaches(db).jsonColumns
+	if columns, ok := cache.Load(tableName); ok {
+		return columns.(map[string]bool), nil
+	}
+
+	tableArg := tableName
+	if databaseType == SQLServer {
+		// OBJECT_ID parses the name like SQL does
+		quotedTableName, err := quoteIdentifier(tableName, databaseType)
+		if err != nil {
+			return nil, err
+		}
+		tableArg = quotedTableName
+	}
+
+	rows, err := queryRows(db, o, query, tableArg)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+
+	columns := map[string]bool{}
+	for rows.Next() {
+		var columnName string
+		if err := rows.Scan(&columnName); err != nil {
+			return nil, err
+		}
+		columns[columnName] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	cache.Store(tableName, columns)
+
+	return columns, nil
+}
+
+// Filter is a condition on the records of a table, see Where and WhereJSON.
+type Filter struct {
+	Column string
+	// Path leads to a value inside a JSON column, object keys or array
+	// indexes. The value is compared as text.
+	Path     []string
+	Operator string
+	// Value is ignored by IS NULL and IS NOT NULL.
+	Value interface{}
+}
+
+// Where compares a column: Where("status", "=", "active").
+func Where(column, operator string, value interface{}) Filter {
+	return Filter{Column: column, Operator: operator, Value: value}
+}
+
+// WhereJSON compares a value inside a JSON column:
+// WhereJSON("data", []string{"status"}, "=", "active") is the equivalent of
+// data->>'status' = ? on PostgreSQL, rendered with the JSON functions of each
+// dialect.
+func WhereJSON(column string, path []string, operator string, value interface{}) Filter {
+	return Filter{Column: column, Path: path, Operator: operator, Value: value}
+}
+
+// WithFilters keeps the records matching all the filters.
+func WithFilters(filters ...Filter) Option {
+	return func(o *options) {
+		o.filters = append(o.filters, filters...)
+	}
+}
+
+var filterOperators = map[string]bool{
+	"=": true, "<>": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+	"LIKE": true, "NOT LIKE": true, "IS NULL": true, "IS NOT NULL": true,
+}
+
+// Renders the filters joined by AND, placeholders numbered from position on.
+func renderFilters(filters []Filter, databaseType DatabaseType, position int) (string, []interface{}, error) {
+	conditions := make([]string, 0, len(filters))
+	var args []interface{}
+
+	for _, filter := range filters {
+		operator := strings.ToUpper(strings.Join(strings.Fields(filter.Operator), " "))
+		if !filterOperators[operator] {
+			return "", nil, fmt.Errorf("unsupported filter operator %q", filter.Operator)
+		}
+
+		operand, operandArgs, err := renderFilterOperand(filter, databaseType, position+len(args))
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, operandArgs...)
+
+		if operator == "IS NULL" || operator == "IS NOT NULL" {
+			conditions = append(conditions, fmt.Sprintf("%s %s", operand, operator))
+			continue
+		}
+
+		placeholder, err := getPlaceholder(databaseType, position+len(args))
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, filter.Value)
+
+		conditions = append(conditions, fmt.Sprintf("%s %s %s", operand, operator, placeholder))
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// Renders the column, or the extraction of the JSON value at the path as
+// text.
+func renderFilterOperand(filter Filter, databaseType DatabaseType, position int) (string, []interface{}, error) {
+	quotedColumn, err := quoteIdentifier(filter.Column, databaseType)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(filter.Path) == 0 {
+		return quotedColumn, nil, nil
+	}
+
+	template, err := getQueryForJSONExtract(databaseType)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch databaseType {
+	case PostgreSQL, CockroachDB:
+		// one argument per key
+		placeholders, err := getPlaceholders(databaseType, position, len(filter.Path))
+		if err != nil {
+			return "", nil, err
+		}
+		args := make([]interface{}, len(filter.Path))
+		for index, key := range filter.Path {
+			args[index] = key
+		}
+		return fmt.Sprintf(template, quotedColumn, strings.Join(placeholders, ", ")), args, nil
+	case Oracle:
+		// Oracle only takes literal paths
+		literal := "'" + strings.ReplaceAll(jsonPath(filter.Path), "'", "''") + "'"
+		return fmt.Sprintf(template, quotedColumn, literal), nil, nil
+	default:
+		placeholder, err := getPlaceholder(databaseType, position)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf(template, quotedColumn, placeholder), []interface{}{jsonPath(filter.Path)}, nil
+	}
+}
+
+// Example: ["items", "0", "name"] -> $."items"[0]."name"
+func jsonPath(path []string) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, key := range path {
+		if index, err := strconv.Atoi(key); err == nil && index >= 0 {
+			fmt.Fprintf(&b, "[%d]", index)
+			continue
+		}
+		b.WriteString(`."`)
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(key))
+		b.WriteString(`"`)
+	}
+	return b.String()
+}
+
+// Appends the WHERE clause of the filters of the call to the query.
+func applyFilters(query string, o *options, databaseType DatabaseType) (string, []interface{}, error) {
+	if len(o.filters) == 0 {
+		return query, nil, nil
+	}
+
+	conditions, args, err := renderFilters(o.filters, databaseType, 1)
+	if err != nil {
+		return "", nil, err
+	}
+
+	args, err = encodeValues(o, args)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return query + " WHERE " + conditions, args, nil
+}
@@ -13,18 +13,33 @@ import (
 )
 
 func ConnectDB(connInfo *DBConnection) (*sql.DB, error) {
-	connStr, err := getConnectionString(connInfo)
+	return connectDB(connInfo, false)
+}
+
+// CloseDB closes the connection and forgets what the package keeps for it:
+// the cached columns, the hooks, the guard and the audit sink. Closing the
+// connection directly leaves them in memory for as long as the process runs.
+func CloseDB(db *sql.DB) error {
+	ResetColumnCache(db)
+	ClearHooks(db)
+	SetGuard(db, nil)
+	SetAuditSink(db, nil)
+	return db.Close()
+}
+
+func connectDB(connInfo *DBConnection, readOnly bool) (*sql.DB, error) {
+	connStr, err := getConnectionString(connInfo, readOnly)
 	if err != nil {
 		return nil, err
 	}
 
 	db, err := sql.Open(string(connInfo.Type), connStr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database connection: %v", err)
+		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
 
 	if err = db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %v", err)
+		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
 	return db, nil
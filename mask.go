@@ -0,0 +1,372 @@
+package sqlutils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Masking holds the rules masking the values of columns, keyed by column
+// name or by table and column name as "table.column", the latter taking
+// precedence. Rules deriving the masked value from the value, all but
+// MaskRedact and MaskTruncate, key it with the secret: with the same secret a
+// value is masked the same way in every table and call, so that joins on
+// masked columns still match.
+type Masking struct {
+	Secret  string
+	Columns map[string]MaskRule
+}
+
+// MaskRule returns the masked value of a column. It is not called for NULL
+// values.
+type MaskRule func(column string, value interface{}, secret []byte) (interface{}, error)
+
+// WithMasking masks the values DuplicateTable copies, ExportTable and Dump
+// write, BulkLoad loads and SyncTable takes from the source. Masked text
+// loaded into a table is cut to the length of its column.
+func WithMasking(masking *Masking) Option {
+	return func(o *options) {
+		o.masking = masking
+	}
+}
+
+// Keeps a helper called by another one from masking values masked already.
+func withoutMasking() Option {
+	return func(o *options) {
+		o.masking = nil
+	}
+}
+
+func (m *Masking) rule(tableName, column string) MaskRule {
+	if rule, ok := m.Columns[tableName+"."+column]; ok {
+		return rule
+	}
+	return m.Columns[column]
+}
+
+// Returns a copy of the row of the table with the values of the columns
+// masked, the row itself without masking. Masked text is cut to the length of
+// its column in schemas, nil when the values are not loaded into a table.
+func maskRow(
+	o *options,
+	tableName string,
+	columns []string,
+	schemas map[string]ColumnSchema,
+	row []interface{},
+) ([]interface{}, error) {
+	if o.masking == nil {
+		return row, nil
+	}
+
+	masked := make([]interface{}, len(row))
+	copy(masked, row)
+
+	secret := []byte(o.masking.Secret)
+	for i, column := range columns {
+		rule := o.masking.rule(tableName, column)
+		if rule == nil || masked[i] == nil {
+			continue
+		}
+
+		value, err := rule(column, masked[i], secret)
+		if err != nil {
+			return nil, fmt.Errorf("masking column %s: %w", column, err)
+		}
+		if text, ok := value.(string); ok {
+			if length := schemas[column].Length; length > 0 && utf8.RuneCountInString(text) > length {
+				value = string([]rune(text)[:length])
+			}
+		}
+		masked[i] = value
+	}
+
+	return masked, nil
+}
+
+// Reads the schemas of the columns of the table the masked values are loaded
+// into, nil without masking.
+func getMaskedColumnSchemas(db *sql.DB, o *options, tableName string, dbType DatabaseType) (map[string]ColumnSchema, error) {
+	if o.masking == nil {
+		return nil, nil
+	}
+
+	columns, err := getColumnSchemas(db, o, tableName, dbType)
+	if err != nil {
+		return nil, err
+	}
+	schemas := make(map[string]ColumnSchema, len(columns))
+	for _, column := range columns {
+		schemas[column.Name] = column
+	}
+	return schemas, nil
+}
+
+// MaskHash replaces the value with the hex HMAC-SHA256 of its text.
+func MaskHash() MaskRule {
+	return func(_ string, value interface{}, secret []byte) (interface{}, error) {
+		return hex.EncodeToString(maskDigest(value, secret)), nil
+	}
+}
+
+// MaskRedact replaces every value with the replacement.
+func MaskRedact(replacement interface{}) MaskRule {
+	return func(string, interface{}, []byte) (interface{}, error) {
+		return replacement, nil
+	}
+}
+
+// MaskTruncate keeps the first n characters of text and the first n bytes of
+// binary values, other values are left as they are.
+func MaskTruncate(n int) MaskRule {
+	return func(_ string, value interface{}, _ []byte) (interface{}, error) {
+		switch v := value.(type) {
+		case string:
+			runes := []rune(v)
+			if len(runes) > n {
+				return string(runes[:n]), nil
+			}
+			return v, nil
+		case []byte:
+			if len(v) > n {
+				return v[:n], nil
+			}
+			return v, nil
+		default:
+			return value, nil
+		}
+	}
+}
+
+// MaskKeepFormat replaces the digits with digits and the letters with letters
+// of the same case, leaving the other characters, such as the separators of
+// phone numbers and emails, as they are. Numbers keep their number of digits,
+// binary values their length and other values are left as they are.
+func MaskKeepFormat() MaskRule {
+	return func(_ string, value interface{}, secret []byte) (interface{}, error) {
+		r := maskRand(value, secret)
+
+		switch v := value.(type) {
+		case string:
+			return keepFormat(r, v), nil
+		case []byte:
+			return randomBytes(r, len(v)), nil
+		case float32:
+			return strconv.ParseFloat(keepFormat(r, strconv.FormatFloat(float64(v), 'f', -1, 32)), 64)
+		case float64:
+			return strconv.ParseFloat(keepFormat(r, strconv.FormatFloat(v, 'f', -1, 64)), 64)
+		default:
+			if text, _, isNumber := numberText(v); isNumber {
+				if _, isBool := v.(bool); isBool {
+					return value, nil
+				}
+				for {
+					masked := keepFormat(r, text)
+					var number interface{}
+					var err error
+					switch v.(type) {
+					case uint, uint8, uint16, uint32, uint64:
+						number, err = strconv.ParseUint(masked, 10, 64)
+					default:
+						number, err = strconv.ParseInt(masked, 10, 64)
+					}
+					// 19 digits may come out beyond 64 bits, drawn again then
+					if !errors.Is(err, strconv.ErrRange) {
+						return number, err
+					}
+				}
+			}
+			return value, nil
+		}
+	}
+}
+
+// MaskFake replaces the value with a made up one of the same type, text
+// matching the name of the column as GenerateRows draws it.
+func MaskFake() MaskRule {
+	return func(column string, value interface{}, secret []byte) (interface{}, error) {
+		r := maskRand(value, secret)
+
+		switch v := value.(type) {
+		case string:
+			return randomText(r, ColumnSchema{Name: column}, false, 0), nil
+		case []byte:
+			return randomBytes(r, len(v)), nil
+		case bool:
+			return r.Intn(2) == 1, nil
+		case float32, float64:
+			return math.Round(r.Float64()*100000) / 100, nil
+		case time.Time:
+			return randomTime(r, ColumnSchema{}, false, 0), nil
+		case map[string]interface{}:
+			return map[string]interface{}{}, nil
+		case []interface{}:
+			return []interface{}{}, nil
+		default:
+			if _, _, isNumber := numberText(v); isNumber {
+				return r.Int63n(1000000), nil
+			}
+			return randomText(r, ColumnSchema{Name: column}, false, 0), nil
+		}
+	}
+}
+
+// MaskPseudonym replaces the value with a pseudonym of the same kind:
+// integers with integers, UUIDs with UUIDs, binary values with as many bytes
+// and other text with "p" followed by 16 hex digits. Times are left as they
+// are. Integers are shuffled within their range, those fitting in 32 bits
+// staying there so that INT keys remain valid, and distinct integers get
+// distinct pseudonyms.
+func MaskPseudonym() MaskRule {
+	return func(_ string, value interface{}, secret []byte) (interface{}, error) {
+		digest := maskDigest(value, secret)
+		number := int64(binary.BigEndian.Uint64(digest)>>11) + 1
+
+		switch v := value.(type) {
+		case string:
+			if _, err := canonicalUUID(v); err == nil {
+				// version 4, RFC 4122 variant
+				digest[6] = digest[6]&0x0f | 0x40
+				digest[8] = digest[8]&0x3f | 0x80
+				return formatUUID(digest[:16]), nil
+			}
+			return "p" + hex.EncodeToString(digest[:8]), nil
+		case []byte:
+			return randomBytes(maskRand(value, secret), len(v)), nil
+		case float32, float64:
+			return float64(number), nil
+		case bool, time.Time:
+			return value, nil
+		default:
+			if text, _, isNumber := numberText(v); isNumber {
+				if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+					return pseudonymInteger(i, secret), nil
+				}
+				return number, nil
+			}
+			return "p" + hex.EncodeToString(digest[:8]), nil
+		}
+	}
+}
+
+// Maps the integer to another one of its range, 1 to 2^31-1 or 2^31 to
+// 2^63-1 and the same ranges of negative integers, 0 staying 0. Within a
+// range the mapping is a permutation keyed by the secret.
+func pseudonymInteger(i int64, secret []byte) int64 {
+	switch {
+	case i == 0:
+		return 0
+	case i < 0:
+		// -1 to -2^63 mirror 0 to 2^63-1
+		return -1 - pseudonymInteger(-(i+1), secret)
+	case i < math.MaxInt32+1:
+		return int64(permuteBelow(uint64(i-1), math.MaxInt32, secret)) + 1
+	default:
+		return int64(permuteBelow(uint64(i-math.MaxInt32-1), math.MaxInt64-math.MaxInt32, secret)) + math.MaxInt32 + 1
+	}
+}
+
+// Permutes the integers below n with a Feistel network over as many bits as
+// n needs, applied again until the result is below n.
+func permuteBelow(x, n uint64, secret []byte) uint64 {
+	width := bits.Len64(n - 1)
+	for {
+		x = feistel(x, width, secret)
+		if x < n {
+			return x
+		}
+	}
+}
+
+func feistel(x uint64, width int, secret []byte) uint64 {
+	rightWidth := width / 2
+	leftWidth := width - rightWidth
+	left, right := x>>rightWidth, x&(1<<rightWidth-1)
+
+	for round := byte(0); round < 4; round++ {
+		var input [9]byte
+		input[0] = round
+		binary.BigEndian.PutUint64(input[1:], right)
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(input[:])
+		f := binary.BigEndian.Uint64(mac.Sum(nil))
+
+		left, right = right, (left^f)&(1<<leftWidth-1)
+		leftWidth, rightWidth = rightWidth, leftWidth
+	}
+
+	return left<<rightWidth | right
+}
+
+// Keys the text of the value with the secret. Values read as different types
+// by different drivers, such as integers and decimals, share their text.
+func maskDigest(value interface{}, secret []byte) []byte {
+	var text []byte
+	switch v := value.(type) {
+	case []byte:
+		text = v
+	case string:
+		text = []byte(v)
+	case time.Time:
+		text = []byte(v.UTC().Format(time.RFC3339Nano))
+	default:
+		text = []byte(fmt.Sprint(v))
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(text)
+	return mac.Sum(nil)
+}
+
+// Returns a random source seeded by the keyed value, for masked values that
+// are the same for the same value.
+func maskRand(value interface{}, secret []byte) *rand.Rand {
+	return rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(maskDigest(value, secret)))))
+}
+
+func keepFormat(r *rand.Rand, text string) string {
+	var b strings.Builder
+	leading := true
+	for _, c := range text {
+		switch {
+		case c >= '0' && c <= '9':
+			// leading zeros stay and no new one appears, so numbers keep
+			// their size
+			switch {
+			case leading && c == '0':
+				b.WriteByte('0')
+			case leading:
+				b.WriteByte(byte('1' + r.Intn(9)))
+				leading = false
+			default:
+				b.WriteByte(byte('0' + r.Intn(10)))
+			}
+		case unicode.IsUpper(c):
+			b.WriteByte(byte('A' + r.Intn(26)))
+		case unicode.IsLower(c):
+			b.WriteByte(byte('a' + r.Intn(26)))
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+func randomBytes(r *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(r.Intn(256))
+	}
+	return b
+}
@@ -0,0 +1,154 @@
+package sqlutils
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMaskPseudonymIntegers(t *testing.T) {
+	rule := MaskPseudonym()
+	secret := []byte("secret")
+
+	seen := map[int64]int64{}
+	for _, value := range []int64{1, 2, 3, 42, 1000, math.MaxInt32, -1, -2, math.MinInt32} {
+		masked, err := rule("id", value, secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pseudonym, ok := masked.(int64)
+		if !ok {
+			t.Fatalf("pseudonym of %d is %T, want int64", value, masked)
+		}
+		if pseudonym < math.MinInt32 || pseudonym > math.MaxInt32 {
+			t.Fatalf("pseudonym of %d is %d, outside of the 32 bit range", value, pseudonym)
+		}
+		if (pseudonym < 0) != (value < 0) {
+			t.Fatalf("pseudonym of %d is %d, with another sign", value, pseudonym)
+		}
+		if original, ok := seen[pseudonym]; ok {
+			t.Fatalf("%d and %d have the same pseudonym %d", original, value, pseudonym)
+		}
+		seen[pseudonym] = value
+	}
+
+	for _, value := range []int64{math.MaxInt32 + 1, 1 << 53, math.MaxInt64, math.MinInt64} {
+		masked, err := rule("id", value, secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pseudonym := masked.(int64); pseudonym >= math.MinInt32 && pseudonym <= math.MaxInt32 {
+			t.Fatalf("pseudonym of %d is %d, within the 32 bit range", value, pseudonym)
+		}
+	}
+
+	// drivers return integers as other types
+	want, err := rule("id", int64(42), secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []interface{}{int32(42), uint8(42)} {
+		masked, err := rule("id", value, secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if masked != want {
+			t.Fatalf("pseudonym of %T 42 is %v, want %v", value, masked, want)
+		}
+	}
+}
+
+func TestMaskPseudonymSameAcrossTables(t *testing.T) {
+	db := openTestDB(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(8))",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER, total REAL)",
+		"INSERT INTO users VALUES (1, 'ann'), (2, 'bob'), (2147483647, 'max')",
+		"INSERT INTO orders VALUES (10, 1, 9.5), (11, 2, 20), (12, 2147483647, 1.25)",
+	)
+
+	masking := &Masking{Secret: "s", Columns: map[string]MaskRule{
+		"users.id":       MaskPseudonym(),
+		"orders.user_id": MaskPseudonym(),
+		"name":           MaskPseudonym(),
+	}}
+	if _, err := DuplicateTable(db, "users", "users_copy", SQLite, WithMasking(masking)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DuplicateTable(db, "orders", "orders_copy", SQLite, WithMasking(masking)); err != nil {
+		t.Fatal(err)
+	}
+
+	var joined, outOfRange int64
+	err := db.QueryRow("SELECT COUNT(*) FROM orders_copy AS o JOIN users_copy AS u ON u.id = o.user_id").Scan(&joined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joined != 3 {
+		t.Fatalf("%d masked orders join their masked user, want 3", joined)
+	}
+	err = db.QueryRow("SELECT COUNT(*) FROM users_copy WHERE id < 1 OR id > 2147483647").Scan(&outOfRange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outOfRange != 0 {
+		t.Fatalf("%d masked keys left the range of the column", outOfRange)
+	}
+
+	// "p" and 16 hex digits cut to VARCHAR(8)
+	rows := readAllRows(t, db, "SELECT name FROM users_copy")
+	for _, row := range rows {
+		name, _ := row[0].(string)
+		if !strings.HasPrefix(name, "p") || utf8.RuneCountInString(name) != 8 {
+			t.Fatalf("masked name %q does not fit VARCHAR(8)", name)
+		}
+	}
+}
+
+func TestMaskKeepFormatStaysWithin64Bits(t *testing.T) {
+	rule := MaskKeepFormat()
+	for i := int64(0); i < 200; i++ {
+		value := int64(math.MaxInt64) - i
+		masked, err := rule("amount", value, []byte{byte(i)})
+		if err != nil {
+			t.Fatalf("masking %d: %v", value, err)
+		}
+		if text := strings.TrimPrefix(formatLiteral(masked), "-"); len(text) != 19 {
+			t.Fatalf("masked %d is %v, want 19 digits", value, masked)
+		}
+	}
+
+	masked, err := rule("price", 0.0000123, []byte("s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price := masked.(float64); price >= 0.0001 || price <= 0 {
+		t.Fatalf("masked 0.0000123 is %v, want the same format", price)
+	}
+}
+
+func TestDuplicateTableMaskedDryRun(t *testing.T) {
+	db := openTestDB(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT)",
+		"INSERT INTO users VALUES (1, 'ann@example.com')",
+	)
+
+	plan := &Plan{}
+	masking := &Masking{Secret: "s", Columns: map[string]MaskRule{"email": MaskHash()}}
+	if _, err := DuplicateTable(db, "users", "users_copy", SQLite, WithMasking(masking), WithDryRun(plan)); err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, statement := range plan.Statements {
+		if strings.HasPrefix(statement.SQL, `INSERT INTO "users_copy"`) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("the plan does not insert the masked rows:\n%s", plan)
+	}
+	if exists, err := tableExists(db, newOptions(db, OpGetTables, "", nil), "users_copy", SQLite); err != nil || exists {
+		t.Fatalf("dry run created the copy (%v, %v)", exists, err)
+	}
+}
@@ -0,0 +1,303 @@
+package sqlutils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the latency
+// histogram buckets used when MetricsCollector.Buckets is empty.
+var DefaultLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Columns whose values are redacted from the arguments of slow queries.
+var defaultSensitiveColumns = regexp.MustCompile(`(?i)pass|secret|token|key|credential|auth|ssn|card`)
+
+const redactedValue = "[REDACTED]"
+
+// MetricsCollector is a Hook recording latency histograms and error counts
+// of the statements, labelled with the operation and table of the call that
+// issued them, and keeping the statements slower than SlowThreshold. A call
+// issuing several statements, as DuplicateTable or ImportTable do, adds one
+// sample per statement.
+type MetricsCollector struct {
+	// SlowThreshold flags slower statements, zero disables the detection.
+	SlowThreshold time.Duration
+	// Buckets of the latency histograms in seconds, DefaultLatencyBuckets
+	// when empty. Must not change once the collector is in use.
+	Buckets []float64
+	// MaxSlowQueries is the number of most recent slow queries kept, 100
+	// when zero.
+	MaxSlowQueries int
+	// SensitiveColumns matches the columns whose arguments are redacted from
+	// slow queries, a default pattern (password, secret, token, key...) is
+	// used when nil.
+	SensitiveColumns *regexp.Regexp
+	// OnSlowQuery is called for every slow query, if set.
+	OnSlowQuery func(SlowQuery)
+
+	mutex       sync.Mutex
+	stats       map[metricsKey]*OperationStats
+	slowQueries []SlowQuery
+	slowTotal   int64
+}
+
+// NewMetricsCollector returns a collector flagging the statements slower
+// than slowThreshold.
+func NewMetricsCollector(slowThreshold time.Duration) *MetricsCollector {
+	return &MetricsCollector{SlowThreshold: slowThreshold}
+}
+
+type metricsKey struct {
+	operation Operation
+	table     string
+}
+
+// OperationStats are the metrics of the statements issued by one operation
+// on one table.
+type OperationStats struct {
+	Operation Operation
+	Table     string
+	// Count and Errors count the statements, Total is their time.
+	Count  int64
+	Errors int64
+	Total  time.Duration
+	// BucketCounts[i] counts the statements of at most Buckets[i] seconds,
+	// cumulatively like Prometheus histograms.
+	Buckets      []float64
+	BucketCounts []int64
+}
+
+// SlowQuery is a statement that took longer than the threshold.
+type SlowQuery struct {
+	Operation Operation
+	Table     string
+	SQL       string
+	Args      []interface{}
+	StartedAt time.Time
+	Duration  time.Duration
+	Err       error
+}
+
+// MetricsSnapshot is a copy of the metrics at a point in time.
+type MetricsSnapshot struct {
+	Operations       []OperationStats
+	SlowQueries      []SlowQuery
+	SlowQueriesTotal int64
+}
+
+func (c *MetricsCollector) BeforeQuery(ctx context.Context, _ *QueryEvent) context.Context {
+	return ctx
+}
+
+func (c *MetricsCollector) AfterQuery(_ context.Context, event *QueryEvent) {
+	var slowQuery *SlowQuery
+	if c.SlowThreshold > 0 && event.Duration >= c.SlowThreshold {
+		slowQuery = &SlowQuery{
+			Operation: event.Operation,
+			Table:     event.Table,
+			SQL:       event.SQL,
+			Args:      c.redactArgs(event.SQL, event.Args),
+			StartedAt: event.StartedAt,
+			Duration:  event.Duration,
+			Err:       event.Err,
+		}
+	}
+
+	c.mutex.Lock()
+
+	if c.stats == nil {
+		c.stats = map[metricsKey]*OperationStats{}
+	}
+
+	key := metricsKey{event.Operation, event.Table}
+	stats, ok := c.stats[key]
+	if !ok {
+		buckets := c.Buckets
+		if len(buckets) == 0 {
+			buckets = DefaultLatencyBuckets
+		}
+		stats = &OperationStats{
+			Operation:    event.Operation,
+			Table:        event.Table,
+			Buckets:      buckets,
+			BucketCounts: make([]int64, len(buckets)),
+		}
+		c.stats[key] = stats
+	}
+
+	stats.Count++
+	stats.Total += event.Duration
+	if event.Err != nil {
+		stats.Errors++
+	}
+	seconds := event.Duration.Seconds()
+	for index, bound := range stats.Buckets {
+		if seconds <= bound {
+			stats.BucketCounts[index]++
+		}
+	}
+
+	if slowQuery != nil {
+		maxSlowQueries := c.MaxSlowQueries
+		if maxSlowQueries <= 0 {
+			maxSlowQueries = 100
+		}
+		c.slowTotal++
+		c.slowQueries = append(c.slowQueries, *slowQuery)
+		if len(c.slowQueries) > maxSlowQueries {
+			c.slowQueries = c.slowQueries[len(c.slowQueries)-maxSlowQueries:]
+		}
+	}
+
+	c.mutex.Unlock()
+
+	if slowQuery != nil && c.OnSlowQuery != nil {
+		c.OnSlowQuery(*slowQuery)
+	}
+}
+
+// Snapshot returns a copy of the metrics collected so far, ordered by
+// operation and table.
+func (c *MetricsCollector) Snapshot() MetricsSnapshot {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	snapshot := MetricsSnapshot{
+		Operations:       make([]OperationStats, 0, len(c.stats)),
+		SlowQueries:      append([]SlowQuery(nil), c.slowQueries...),
+		SlowQueriesTotal: c.slowTotal,
+	}
+	for _, stats := range c.stats {
+		copied := *stats
+		copied.BucketCounts = append([]int64(nil), stats.BucketCounts...)
+		snapshot.Operations = append(snapshot.Operations, copied)
+	}
+	sort.Slice(snapshot.Operations, func(i, j int) bool {
+		a, b := snapshot.Operations[i], snapshot.Operations[j]
+		if a.Operation != b.Operation {
+			return a.Operation < b.Operation
+		}
+		return a.Table < b.Table
+	})
+
+	return snapshot
+}
+
+// Reset clears the collected metrics.
+func (c *MetricsCollector) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.stats = nil
+	c.slowQueries = nil
+	c.slowTotal = 0
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format.
+func (c *MetricsCollector) WritePrometheus(w io.Writer) error {
+	snapshot := c.Snapshot()
+
+	var b strings.Builder
+
+	b.WriteString("# HELP sqlutils_statement_duration_seconds Latency of each statement issued by the helpers.\n")
+	b.WriteString("# TYPE sqlutils_statement_duration_seconds histogram\n")
+	for _, stats := range snapshot.Operations {
+		labels := fmt.Sprintf(`operation="%s",table="%s"`,
+			escapePrometheusLabel(string(stats.Operation)), escapePrometheusLabel(stats.Table))
+		for index, bound := range stats.Buckets {
+			fmt.Fprintf(&b, "sqlutils_statement_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+				labels, strconv.FormatFloat(bound, 'g', -1, 64), stats.BucketCounts[index])
+		}
+		fmt.Fprintf(&b, "sqlutils_statement_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, stats.Count)
+		fmt.Fprintf(&b, "sqlutils_statement_duration_seconds_sum{%s} %s\n",
+			labels, strconv.FormatFloat(stats.Total.Seconds(), 'g', -1, 64))
+		fmt.Fprintf(&b, "sqlutils_statement_duration_seconds_count{%s} %d\n", labels, stats.Count)
+	}
+
+	b.WriteString("# HELP sqlutils_statement_errors_total Statements issued by the helpers that failed.\n")
+	b.WriteString("# TYPE sqlutils_statement_errors_total counter\n")
+	for _, stats := range snapshot.Operations {
+		fmt.Fprintf(&b, "sqlutils_statement_errors_total{operation=\"%s\",table=\"%s\"} %d\n",
+			escapePrometheusLabel(string(stats.Operation)), escapePrometheusLabel(stats.Table), stats.Errors)
+	}
+
+	b.WriteString("# HELP sqlutils_slow_statements_total Statements slower than the slow query threshold.\n")
+	b.WriteString("# TYPE sqlutils_slow_statements_total counter\n")
+	fmt.Fprintf(&b, "sqlutils_slow_statements_total %d\n", snapshot.SlowQueriesTotal)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func escapePrometheusLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// Replaces the arguments bound to sensitive columns with a marker. The
+// columns are found from the INSERT column list or from the "column = ?"
+// comparisons preceding the placeholders.
+func (c *MetricsCollector) redactArgs(query string, args []interface{}) []interface{} {
+	sensitive := c.SensitiveColumns
+	if sensitive == nil {
+		sensitive = defaultSensitiveColumns
+	}
+
+	redacted := append([]interface{}(nil), args...)
+	for index, column := range argColumns(query, len(args)) {
+		if column != "" && sensitive.MatchString(column) {
+			redacted[index] = redactedValue
+		}
+	}
+
+	return redacted
+}
+
+var (
+	insertColumnsRegex = regexp.MustCompile(`(?is)^\s*INSERT\s+INTO\s+\S+\s*\(([^)]*)\)\s*VALUES`)
+	comparisonRegex    = regexp.MustCompile("([`\"\\]\\w]+)\\s*(?:=|<>|!=|<=|>=|<|>|LIKE)\\s*(\\?|\\$\\d+|@p\\d+|:\\d+)")
+)
+
+// Best effort mapping of the arguments of a generated statement to the
+// columns they are bound to, unknown columns are left empty.
+func argColumns(query string, argCount int) []string {
+	columns := make([]string, argCount)
+
+	if match := insertColumnsRegex.FindStringSubmatch(query); match != nil {
+		// multi-row inserts repeat the column list for every row
+		insertColumns := strings.Split(match[1], ",")
+		for index := range columns {
+			columns[index] = unquoteIdentifier(insertColumns[index%len(insertColumns)])
+		}
+		return columns
+	}
+
+	nextArg := 0
+	for _, match := range comparisonRegex.FindAllStringSubmatch(query, -1) {
+		index := nextArg
+		if match[2] != "?" {
+			position, err := strconv.Atoi(strings.TrimLeft(match[2], "$@p:"))
+			if err != nil {
+				continue
+			}
+			index = position - 1
+		} else {
+			nextArg++
+		}
+		if index >= 0 && index < argCount {
+			columns[index] = unquoteIdentifier(match[1])
+		}
+	}
+
+	return columns
+}
+
+func unquoteIdentifier(identifier string) string {
+	return strings.Trim(strings.TrimSpace(identifier), "`\"[]")
+}
@@ -0,0 +1,130 @@
+package sqlutils
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Option tweaks the behaviour of a single table or record helper call.
+// Helpers ignore the options that do not apply to them.
+type Option func(*options)
+
+type options struct {
+	// db, operation and table describe the call, for guards and hooks
+	db        *sql.DB
+	operation Operation
+	table     string
+	ctx       context.Context
+
+	restartIdentity bool
+	cascade         bool
+	ifExists        bool
+	ifNotExists     bool
+	lenientColumns  bool
+	guard           *Guard
+	dryRun          *Plan
+	hooks           []Hook
+	auditSinks      []AuditSink
+	actor           string
+	skipAudit       bool
+	undo            *UndoToken
+	trash           bool
+	skipGuard       bool
+	base64Binary    bool
+	codec           *ValueCodec
+	filters         []Filter
+	columns         []string
+	csvDelimiter    rune
+	csvNoHeader     bool
+	nullString      string
+	columnMapping   map[string]string
+	importMode      ImportMode
+	batchSize       int
+	tables          []string
+	chunkSize       int
+	syncDeletes     bool
+	maxDeletes      int
+	seed            int64
+	seeded          bool
+	generators      map[string]ValueGenerator
+	masking         *Masking
+}
+
+func newOptions(db *sql.DB, operation Operation, tableName string, opts []Option) *options {
+	o := &options{ctx: context.Background()}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	o.db = db
+	// a helper called by another one keeps the public operation it runs for
+	if o.operation == "" {
+		o.operation = operation
+	}
+	o.table = tableName
+	return o
+}
+
+// Hands the options of a call down to the helpers it calls, which run on
+// behalf of its operation.
+func inherit(o *options) Option {
+	return func(dst *options) {
+		*dst = *o
+	}
+}
+
+// WithContext runs the statements of the call with the context, which is
+// also handed to the hooks.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// WithRestartIdentity resets identity / sequence / auto increment counters
+// of the affected table.
+func WithRestartIdentity() Option {
+	return func(o *options) {
+		o.restartIdentity = true
+	}
+}
+
+// WithCascade propagates the operation to the tables that reference the
+// affected table through foreign keys.
+func WithCascade() Option {
+	return func(o *options) {
+		o.cascade = true
+	}
+}
+
+// WithIfExists turns a missing source table into a no-op instead of an error.
+func WithIfExists() Option {
+	return func(o *options) {
+		o.ifExists = true
+	}
+}
+
+// WithIfNotExists turns an already existing target table into a no-op
+// instead of an error.
+func WithIfNotExists() Option {
+	return func(o *options) {
+		o.ifNotExists = true
+	}
+}
+
+// WithLenientColumns drops record keys that are not columns of the table
+// instead of rejecting the record with an UnknownColumnsError.
+func WithLenientColumns() Option {
+	return func(o *options) {
+		o.lenientColumns = true
+	}
+}
+
+// WithGuard checks the call against the guard, on top of the guard registered
+// for the connection.
+func WithGuard(guard *Guard) Option {
+	return func(o *options) {
+		o.guard = guard
+	}
+}
@@ -2,185 +2,830 @@ package sqlutils
 
 import (
 	"fmt"
+	"strings"
+	"unicode/utf8"
 )
 
-var quoteCharMap = map[DatabaseType]string{
-	MySQL:       "`",
-	MariaDB:     "`",
-	SQLite:      "\"",
-	CockroachDB: "\"",
-	PostgreSQL:  "\"",
-	SQLServer:   "\"",
-	Oracle:      "\"",
+// Opening and closing quote of identifiers, a closing quote inside the
+// identifier is escaped by doubling it.
+var identifierQuoteMap = map[DatabaseType][2]string{
+	MySQL:       {"`", "`"},
+	MariaDB:     {"`", "`"},
+	SQLite:      {"\"", "\""},
+	CockroachDB: {"\"", "\""},
+	PostgreSQL:  {"\"", "\""},
+	SQLServer:   {"[", "]"},
+	Oracle:      {"\"", "\""},
+}
+
+// Quotes a table, schema or column name so that it can be safely pasted into SQL.
+func quoteIdentifier(name string, databaseType DatabaseType) (string, error) {
+	quotes, ok := identifierQuoteMap[databaseType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
+	}
+	if name == "" || strings.ContainsRune(name, 0) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidIdentifier, name)
+	}
+	escaped := strings.ReplaceAll(name, quotes[1], quotes[1]+quotes[1])
+	return quotes[0] + escaped + quotes[1], nil
+}
+
+func quoteIdentifiers(names []string, databaseType DatabaseType) ([]string, error) {
+	quoted := make([]string, len(names))
+	for index, name := range names {
+		var err error
+		quoted[index], err = quoteIdentifier(name, databaseType)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return quoted, nil
+}
+
+// Longest identifier each database accepts, in bytes for PostgreSQL,
+// CockroachDB and Oracle and in characters for the others. PostgreSQL silently
+// truncates longer names. SQLite has no limit.
+var identifierLengthMap = map[DatabaseType]int{
+	MySQL:       64,
+	MariaDB:     64,
+	CockroachDB: 63,
+	PostgreSQL:  63,
+	SQLServer:   128,
+	Oracle:      128,
+}
+
+// Fails with ErrInvalidIdentifier for names too long for the database.
+func checkIdentifierLength(name string, databaseType DatabaseType) error {
+	limit, ok := identifierLengthMap[databaseType]
+	if !ok {
+		return nil
+	}
+
+	length := utf8.RuneCountInString(name)
+	switch databaseType {
+	case PostgreSQL, CockroachDB, Oracle:
+		length = len(name)
+	}
+
+	if length > limit {
+		return fmt.Errorf("%w: %q is longer than %d", ErrInvalidIdentifier, name, limit)
+	}
+	return nil
 }
 
 var placeholderMap = map[DatabaseType]string{
 	MySQL:       "?",
 	MariaDB:     "?",
 	SQLite:      "?",
-	CockroachDB: "?", // could be $ instead
+	CockroachDB: "$",
 	PostgreSQL:  "$",
 	SQLServer:   "@p",
 	Oracle:      ":",
 }
 
+// Returns the placeholder of the bind argument at the given 1-based position.
+func getPlaceholder(databaseType DatabaseType, position int) (string, error) {
+	placeholder, ok := placeholderMap[databaseType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
+	}
+
+	switch databaseType {
+	case PostgreSQL, CockroachDB, SQLServer, Oracle:
+		return fmt.Sprintf("%s%d", placeholder, position), nil
+	}
+
+	return placeholder, nil
+}
+
+// Returns the placeholders of count bind arguments, starting at the given position.
+func getPlaceholders(databaseType DatabaseType, start, count int) ([]string, error) {
+	placeholders := make([]string, count)
+	for index := range placeholders {
+		var err error
+		placeholders[index], err = getPlaceholder(databaseType, start+index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return placeholders, nil
+}
+
+// The base tables of the database, views left out.
 var tablesQueryTemplates = map[DatabaseType]string{
-	MySQL:       "SELECT table_name FROM information_schema.tables WHERE table_schema = '%s';",
-	MariaDB:     "SELECT table_name FROM information_schema.tables WHERE table_schema = '%s';",
-	SQLServer:   "SELECT table_name FROM information_schema.tables WHERE table_catalog = '%s' AND table_schema = 'dbo';",
-	PostgreSQL:  "SELECT table_name FROM information_schema.tables WHERE table_catalog = '%s' AND table_schema = 'public';",
+	MySQL:       "SELECT table_name FROM information_schema.tables WHERE table_schema = COALESCE(NULLIF(?, ''), DATABASE()) AND table_type = 'BASE TABLE';",
+	MariaDB:     "SELECT table_name FROM information_schema.tables WHERE table_schema = COALESCE(NULLIF(?, ''), DATABASE()) AND table_type IN ('BASE TABLE', 'SYSTEM VERSIONED');",
+	SQLServer:   "SELECT table_name FROM information_schema.tables WHERE table_catalog = COALESCE(NULLIF(@p1, ''), DB_NAME()) AND table_schema = 'dbo' AND table_type = 'BASE TABLE';",
+	PostgreSQL:  "SELECT table_name FROM information_schema.tables WHERE table_catalog = COALESCE(NULLIF($1, ''), current_database()) AND table_schema = 'public' AND table_type = 'BASE TABLE';",
 	SQLite:      "SELECT name FROM sqlite_master WHERE type='table';",
-	Oracle:      "SELECT table_name FROM all_tables WHERE owner = '%s';",
-	CockroachDB: "SELECT table_name FROM information_schema.tables WHERE table_catalog = '%s' AND table_schema = 'public';",
+	Oracle:      "SELECT table_name FROM all_tables WHERE owner = NVL(:1, USER)",
+	CockroachDB: "SELECT table_name FROM information_schema.tables WHERE table_catalog = COALESCE(NULLIF($1, ''), current_database()) AND table_schema = 'public' AND table_type = 'BASE TABLE';",
 }
 
-func getQueryForTables(dbName string, databaseType DatabaseType) (string, error) {
+func getQueryForTables(databaseType DatabaseType) (string, error) {
 	template, ok := tablesQueryTemplates[databaseType]
 	if !ok {
-		return "", fmt.Errorf("unsupported database type: %s", databaseType)
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
 	}
-	return fmt.Sprintf(template, dbName), nil
+	return template, nil
 }
 
 var allRecordsQueryTemplates = map[DatabaseType]string{
-	MySQL:       "SELECT * FROM `%s`;",
-	MariaDB:     "SELECT * FROM `%s`;",
-	SQLServer:   "SELECT * FROM dbo.[%s];",
-	PostgreSQL:  "SELECT * FROM \"%s\";",
-	SQLite:      "SELECT * FROM \"%s\";",
-	Oracle:      "SELECT * FROM \"%s\";",
-	CockroachDB: "SELECT * FROM \"public\".\"%s\";",
+	MySQL:       "SELECT * FROM %s",
+	MariaDB:     "SELECT * FROM %s",
+	SQLServer:   "SELECT * FROM dbo.%s",
+	PostgreSQL:  "SELECT * FROM %s",
+	SQLite:      "SELECT * FROM %s",
+	Oracle:      "SELECT * FROM %s",
+	CockroachDB: "SELECT * FROM \"public\".%s",
 }
 
 func getQueryForAllRecords(tableName string, databaseType DatabaseType) (string, error) {
 	template, ok := allRecordsQueryTemplates[databaseType]
 	if !ok {
-		return "", fmt.Errorf("unsupported database type: %s", databaseType)
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
+	}
+	quotedTableName, err := quoteIdentifier(tableName, databaseType)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(template, quotedTableName), nil
+}
+
+var selectColumnsQueryTemplates = map[DatabaseType]string{
+	MySQL:       "SELECT %s FROM %s",
+	MariaDB:     "SELECT %s FROM %s",
+	SQLServer:   "SELECT %s FROM dbo.%s",
+	PostgreSQL:  "SELECT %s FROM %s",
+	SQLite:      "SELECT %s FROM %s",
+	Oracle:      "SELECT %s FROM %s",
+	CockroachDB: "SELECT %s FROM \"public\".%s",
+}
+
+// The columns are expected quoted already, or *.
+func getQueryForSelectColumns(tableName string, columns []string, databaseType DatabaseType) (string, error) {
+	template, ok := selectColumnsQueryTemplates[databaseType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
+	}
+	quotedTableName, err := quoteIdentifier(tableName, databaseType)
+	if err != nil {
+		return "", err
 	}
-	return fmt.Sprintf(template, tableName), nil
+	return fmt.Sprintf(template, strings.Join(columns, ", "), quotedTableName), nil
 }
 
 var columnQueryTemplates = map[DatabaseType]string{
 	MySQL:       "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = ? AND TABLE_SCHEMA = DATABASE() ORDER BY ORDINAL_POSITION;",
 	MariaDB:     "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = ? AND TABLE_SCHEMA = DATABASE() ORDER BY ORDINAL_POSITION;",
-	SQLServer:   "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = ? AND TABLE_SCHEMA = 'dbo' ORDER BY ORDINAL_POSITION;",
+	SQLServer:   "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = @p1 AND TABLE_SCHEMA = 'dbo' ORDER BY ORDINAL_POSITION;",
 	PostgreSQL:  "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = $1 AND TABLE_SCHEMA = 'public' ORDER BY ORDINAL_POSITION;",
-	SQLite:      "PRAGMA table_info(?);",
-	Oracle:      "SELECT COLUMN_NAME FROM ALL_TAB_COLUMNS WHERE TABLE_NAME = UPPER(?) AND OWNER = USER ORDER BY COLUMN_ID;",
+	SQLite:      "SELECT name FROM pragma_table_info(?) ORDER BY cid;",
+	Oracle:      "SELECT COLUMN_NAME FROM ALL_TAB_COLUMNS WHERE TABLE_NAME = :1 AND OWNER = USER ORDER BY COLUMN_ID",
 	CockroachDB: "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = $1 AND TABLE_SCHEMA = 'public' ORDER BY ORDINAL_POSITION;",
 }
 
 func getQueryForColumns(databaseType DatabaseType) (string, error) {
 	template, ok := columnQueryTemplates[databaseType]
 	if !ok {
-		return "", fmt.Errorf("unsupported database type: %s", databaseType)
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
 	}
 	return template, nil
 }
 
+// An empty database name stands for the current database. Oracle names are
+// quoted, so like in every Oracle catalog query they are matched exactly.
 var primaryKeyQueryTemplates = map[DatabaseType]string{
-	MySQL:       "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION;",
-	MariaDB:     "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION;",
-	SQLServer:   "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE TABLE_CATALOG = ? AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION;",
+	MySQL:       "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION;",
+	MariaDB:     "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION;",
+	SQLServer:   "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE TABLE_CATALOG = COALESCE(NULLIF(@p1, ''), DB_NAME()) AND TABLE_NAME = @p2 AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION;",
 	PostgreSQL:  "SELECT a.attname AS column_name FROM pg_constraint AS c JOIN pg_attribute AS a ON a.attnum = ANY(c.conkey) AND a.attrelid = c.conrelid WHERE c.contype = 'p' AND c.conrelid = $1::regclass;",
-	SQLite:      "PRAGMA table_info(?);",
-	Oracle:      "SELECT COLUMN_NAME FROM ALL_CONS_COLUMNS WHERE CONSTRAINT_NAME = (SELECT CONSTRAINT_NAME FROM ALL_CONSTRAINTS WHERE CONSTRAINT_TYPE = 'P' AND TABLE_NAME = UPPER(?) AND OWNER = USER) AND TABLE_NAME = UPPER(?) ORDER BY POSITION;",
-	CockroachDB: "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE TABLE_CATALOG = $1 AND TABLE_NAME = $2 AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION;",
+	SQLite:      "SELECT name FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk;",
+	Oracle:      "SELECT cc.COLUMN_NAME FROM ALL_CONSTRAINTS c JOIN ALL_CONS_COLUMNS cc ON cc.OWNER = c.OWNER AND cc.CONSTRAINT_NAME = c.CONSTRAINT_NAME WHERE c.CONSTRAINT_TYPE = 'P' AND c.TABLE_NAME = :1 AND c.OWNER = USER ORDER BY cc.POSITION",
+	CockroachDB: "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE TABLE_CATALOG = COALESCE(NULLIF($1, ''), current_database()) AND TABLE_NAME = $2 AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION;",
 }
 
 func getQueryForPrimaryKeys(databaseType DatabaseType) (string, error) {
 	template, ok := primaryKeyQueryTemplates[databaseType]
 	if !ok {
-		return "", fmt.Errorf("unsupported database type: %s", databaseType)
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
+	}
+	return template, nil
+}
+
+// Columns: name, type, length, precision (fractional digits for times),
+// scale, nullable as YES / NO and the definition of ENUM and SET types.
+var columnSchemaQueryTemplates = map[DatabaseType]string{
+	MySQL:       "SELECT COLUMN_NAME, DATA_TYPE, CHARACTER_MAXIMUM_LENGTH, COALESCE(NUMERIC_PRECISION, DATETIME_PRECISION), NUMERIC_SCALE, IS_NULLABLE, CASE WHEN DATA_TYPE IN ('enum', 'set') THEN COLUMN_TYPE END FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = ? AND TABLE_SCHEMA = DATABASE() ORDER BY ORDINAL_POSITION;",
+	MariaDB:     "SELECT COLUMN_NAME, DATA_TYPE, CHARACTER_MAXIMUM_LENGTH, COALESCE(NUMERIC_PRECISION, DATETIME_PRECISION), NUMERIC_SCALE, IS_NULLABLE, CASE WHEN DATA_TYPE IN ('enum', 'set') THEN COLUMN_TYPE END FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = ? AND TABLE_SCHEMA = DATABASE() ORDER BY ORDINAL_POSITION;",
+	SQLServer:   "SELECT COLUMN_NAME, DATA_TYPE, CHARACTER_MAXIMUM_LENGTH, COALESCE(NUMERIC_PRECISION, CASE WHEN DATA_TYPE IN ('datetime2', 'datetimeoffset', 'time') THEN DATETIME_PRECISION END), NUMERIC_SCALE, IS_NULLABLE, NULL FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = @p1 AND TABLE_SCHEMA = 'dbo' ORDER BY ORDINAL_POSITION;",
+	PostgreSQL:  "SELECT column_name, udt_name, character_maximum_length, COALESCE(numeric_precision, datetime_precision), numeric_scale, is_nullable, NULL FROM information_schema.columns WHERE table_name = $1 AND table_schema = 'public' ORDER BY ordinal_position;",
+	SQLite:      "SELECT name, type, NULL, NULL, NULL, CASE WHEN \"notnull\" = 1 THEN 'NO' ELSE 'YES' END, NULL FROM pragma_table_info(?) ORDER BY cid;",
+	Oracle:      "SELECT COLUMN_NAME, DATA_TYPE, CASE WHEN DATA_TYPE = 'RAW' THEN DATA_LENGTH ELSE NULLIF(CHAR_LENGTH, 0) END, DATA_PRECISION, DATA_SCALE, CASE NULLABLE WHEN 'N' THEN 'NO' ELSE 'YES' END, NULL FROM ALL_TAB_COLUMNS WHERE TABLE_NAME = :1 AND OWNER = USER ORDER BY COLUMN_ID",
+	CockroachDB: "SELECT column_name, udt_name, character_maximum_length, COALESCE(numeric_precision, datetime_precision), numeric_scale, is_nullable, NULL FROM information_schema.columns WHERE table_name = $1 AND table_schema = 'public' ORDER BY ordinal_position;",
+}
+
+func getQueryForColumnSchema(databaseType DatabaseType) (string, error) {
+	template, ok := columnSchemaQueryTemplates[databaseType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
+	}
+	return template, nil
+}
+
+// Columns: constraint name, column, referenced table, referenced column, one
+// row per column in constraint order.
+var foreignKeysQueryTemplates = map[DatabaseType]string{
+	MySQL:       "SELECT CONSTRAINT_NAME, COLUMN_NAME, REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND REFERENCED_TABLE_NAME IS NOT NULL ORDER BY CONSTRAINT_NAME, ORDINAL_POSITION;",
+	MariaDB:     "SELECT CONSTRAINT_NAME, COLUMN_NAME, REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND REFERENCED_TABLE_NAME IS NOT NULL ORDER BY CONSTRAINT_NAME, ORDINAL_POSITION;",
+	SQLServer:   "SELECT kcu.CONSTRAINT_NAME, kcu.COLUMN_NAME, ref.TABLE_NAME, ref.COLUMN_NAME FROM INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS AS rc JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS kcu ON kcu.CONSTRAINT_SCHEMA = rc.CONSTRAINT_SCHEMA AND kcu.CONSTRAINT_NAME = rc.CONSTRAINT_NAME JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS ref ON ref.CONSTRAINT_SCHEMA = rc.UNIQUE_CONSTRAINT_SCHEMA AND ref.CONSTRAINT_NAME = rc.UNIQUE_CONSTRAINT_NAME AND ref.ORDINAL_POSITION = kcu.ORDINAL_POSITION WHERE kcu.TABLE_SCHEMA = 'dbo' AND kcu.TABLE_NAME = @p1 ORDER BY kcu.CONSTRAINT_NAME, kcu.ORDINAL_POSITION;",
+	PostgreSQL:  "SELECT kcu.constraint_name, kcu.column_name, ref.table_name, ref.column_name FROM information_schema.referential_constraints AS rc JOIN information_schema.key_column_usage AS kcu ON kcu.constraint_schema = rc.constraint_schema AND kcu.constraint_name = rc.constraint_name JOIN information_schema.key_column_usage AS ref ON ref.constraint_schema = rc.unique_constraint_schema AND ref.constraint_name = rc.unique_constraint_name AND ref.ordinal_position = kcu.position_in_unique_constraint WHERE kcu.table_schema = 'public' AND kcu.table_name = $1 ORDER BY kcu.constraint_name, kcu.ordinal_position;",
+	SQLite:      "SELECT CAST(id AS TEXT), \"from\", \"table\", COALESCE(\"to\", '') FROM pragma_foreign_key_list(?) ORDER BY id, seq;",
+	Oracle:      "SELECT c.CONSTRAINT_NAME, cc.COLUMN_NAME, r.TABLE_NAME, rc.COLUMN_NAME FROM ALL_CONSTRAINTS c JOIN ALL_CONS_COLUMNS cc ON cc.OWNER = c.OWNER AND cc.CONSTRAINT_NAME = c.CONSTRAINT_NAME JOIN ALL_CONSTRAINTS r ON r.OWNER = c.R_OWNER AND r.CONSTRAINT_NAME = c.R_CONSTRAINT_NAME JOIN ALL_CONS_COLUMNS rc ON rc.OWNER = r.OWNER AND rc.CONSTRAINT_NAME = r.CONSTRAINT_NAME AND rc.POSITION = cc.POSITION WHERE c.CONSTRAINT_TYPE = 'R' AND c.TABLE_NAME = :1 AND c.OWNER = USER ORDER BY c.CONSTRAINT_NAME, cc.POSITION",
+	CockroachDB: "SELECT kcu.constraint_name, kcu.column_name, ref.table_name, ref.column_name FROM information_schema.referential_constraints AS rc JOIN information_schema.key_column_usage AS kcu ON kcu.constraint_schema = rc.constraint_schema AND kcu.constraint_name = rc.constraint_name JOIN information_schema.key_column_usage AS ref ON ref.constraint_schema = rc.unique_constraint_schema AND ref.constraint_name = rc.unique_constraint_name AND ref.ordinal_position = kcu.position_in_unique_constraint WHERE kcu.table_schema = 'public' AND kcu.table_name = $1 ORDER BY kcu.constraint_name, kcu.ordinal_position;",
+}
+
+func getQueryForForeignKeys(databaseType DatabaseType) (string, error) {
+	template, ok := foreignKeysQueryTemplates[databaseType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
+	}
+	return template, nil
+}
+
+// Lists the unique constraints and indexes of a table other than its primary
+// key, as index name and column rows in column order.
+var uniqueKeysQueryTemplates = map[DatabaseType]string{
+	MySQL:       "SELECT INDEX_NAME, COLUMN_NAME FROM INFORMATION_SCHEMA.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND NON_UNIQUE = 0 AND INDEX_NAME <> 'PRIMARY' ORDER BY INDEX_NAME, SEQ_IN_INDEX;",
+	MariaDB:     "SELECT INDEX_NAME, COLUMN_NAME FROM INFORMATION_SCHEMA.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND NON_UNIQUE = 0 AND INDEX_NAME <> 'PRIMARY' ORDER BY INDEX_NAME, SEQ_IN_INDEX;",
+	SQLServer:   "SELECT i.name, c.name FROM sys.indexes AS i JOIN sys.index_columns AS ic ON ic.object_id = i.object_id AND ic.index_id = i.index_id JOIN sys.columns AS c ON c.object_id = ic.object_id AND c.column_id = ic.column_id WHERE i.object_id = OBJECT_ID(@p1) AND i.is_unique = 1 AND i.is_primary_key = 0 AND ic.is_included_column = 0 ORDER BY i.name, ic.key_ordinal;",
+	PostgreSQL:  "SELECT i.relname, a.attname FROM pg_index AS x JOIN pg_class AS i ON i.oid = x.indexrelid JOIN pg_attribute AS a ON a.attrelid = x.indrelid AND a.attnum = ANY(x.indkey) WHERE x.indrelid = $1::regclass AND x.indisunique AND NOT x.indisprimary ORDER BY i.relname, array_position(x.indkey::int2[], a.attnum);",
+	SQLite:      "SELECT il.name, ii.name FROM pragma_index_list(?) AS il JOIN pragma_index_info(il.name) AS ii WHERE il.\"unique\" = 1 AND il.origin <> 'pk' ORDER BY il.name, ii.seqno;",
+	Oracle:      "SELECT ic.INDEX_NAME, ic.COLUMN_NAME FROM USER_INDEXES i JOIN USER_IND_COLUMNS ic ON ic.INDEX_NAME = i.INDEX_NAME WHERE i.TABLE_NAME = :1 AND i.UNIQUENESS = 'UNIQUE' AND NOT EXISTS (SELECT 1 FROM USER_CONSTRAINTS c WHERE c.CONSTRAINT_TYPE = 'P' AND c.INDEX_NAME = i.INDEX_NAME) ORDER BY ic.INDEX_NAME, ic.COLUMN_POSITION",
+	CockroachDB: "SELECT i.relname, a.attname FROM pg_index AS x JOIN pg_class AS i ON i.oid = x.indexrelid JOIN pg_attribute AS a ON a.attrelid = x.indrelid AND a.attnum = ANY(x.indkey) WHERE x.indrelid = $1::regclass AND x.indisunique AND NOT x.indisprimary ORDER BY i.relname, array_position(x.indkey::int2[], a.attnum);",
+}
+
+func getQueryForUniqueKeys(databaseType DatabaseType) (string, error) {
+	template, ok := uniqueKeysQueryTemplates[databaseType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
+	}
+	return template, nil
+}
+
+// Column types used when a column moves to another dialect, keyed by portable
+// type. varchar takes the length, decimal the precision and the scale.
+var columnTypeTemplates = map[string]map[DatabaseType]string{
+	"text": {
+		MySQL: "LONGTEXT", MariaDB: "LONGTEXT", SQLServer: "NVARCHAR(MAX)", PostgreSQL: "TEXT",
+		SQLite: "TEXT", Oracle: "CLOB", CockroachDB: "STRING",
+	},
+	"varchar": {
+		MySQL: "VARCHAR(%d)", MariaDB: "VARCHAR(%d)", SQLServer: "NVARCHAR(%d)", PostgreSQL: "VARCHAR(%d)",
+		SQLite: "VARCHAR(%d)", Oracle: "VARCHAR2(%d CHAR)", CockroachDB: "VARCHAR(%d)",
+	},
+	"binary": {
+		MySQL: "LONGBLOB", MariaDB: "LONGBLOB", SQLServer: "VARBINARY(MAX)", PostgreSQL: "BYTEA",
+		SQLite: "BLOB", Oracle: "BLOB", CockroachDB: "BYTES",
+	},
+	"integer": {
+		MySQL: "BIGINT", MariaDB: "BIGINT", SQLServer: "BIGINT", PostgreSQL: "BIGINT",
+		SQLite: "INTEGER", Oracle: "NUMBER(19)", CockroachDB: "INT8",
+	},
+	"float": {
+		MySQL: "DOUBLE", MariaDB: "DOUBLE", SQLServer: "FLOAT", PostgreSQL: "DOUBLE PRECISION",
+		SQLite: "REAL", Oracle: "BINARY_DOUBLE", CockroachDB: "FLOAT8",
+	},
+	"decimal": {
+		MySQL: "DECIMAL(%d, %d)", MariaDB: "DECIMAL(%d, %d)", SQLServer: "DECIMAL(%d, %d)", PostgreSQL: "NUMERIC(%d, %d)",
+		SQLite: "NUMERIC(%d, %d)", Oracle: "NUMBER(%d, %d)", CockroachDB: "DECIMAL(%d, %d)",
+	},
+	"number": {
+		MySQL: "DECIMAL(65, 30)", MariaDB: "DECIMAL(65, 30)", SQLServer: "DECIMAL(38, 10)", PostgreSQL: "NUMERIC",
+		SQLite: "NUMERIC", Oracle: "NUMBER", CockroachDB: "DECIMAL",
+	},
+	"boolean": {
+		MySQL: "BOOLEAN", MariaDB: "BOOLEAN", SQLServer: "BIT", PostgreSQL: "BOOLEAN",
+		SQLite: "BOOLEAN", Oracle: "NUMBER(1)", CockroachDB: "BOOL",
+	},
+	"date": {
+		MySQL: "DATE", MariaDB: "DATE", SQLServer: "DATE", PostgreSQL: "DATE",
+		SQLite: "DATE", Oracle: "DATE", CockroachDB: "DATE",
+	},
+	"time": {
+		MySQL: "TIME(6)", MariaDB: "TIME(6)", SQLServer: "TIME", PostgreSQL: "TIME",
+		SQLite: "TIME", Oracle: "VARCHAR2(32)", CockroachDB: "TIME",
+	},
+	"timestamp": {
+		MySQL: "DATETIME(6)", MariaDB: "DATETIME(6)", SQLServer: "DATETIME2", PostgreSQL: "TIMESTAMP",
+		SQLite: "DATETIME", Oracle: "TIMESTAMP", CockroachDB: "TIMESTAMP",
+	},
+	"timestamptz": {
+		MySQL: "DATETIME(6)", MariaDB: "DATETIME(6)", SQLServer: "DATETIMEOFFSET", PostgreSQL: "TIMESTAMPTZ",
+		SQLite: "DATETIME", Oracle: "TIMESTAMP WITH TIME ZONE", CockroachDB: "TIMESTAMPTZ",
+	},
+	"json": {
+		MySQL: "JSON", MariaDB: "JSON", SQLServer: "NVARCHAR(MAX)", PostgreSQL: "JSONB",
+		SQLite: "JSON", Oracle: "CLOB", CockroachDB: "JSONB",
+	},
+	"uuid": {
+		MySQL: "CHAR(36)", MariaDB: "CHAR(36)", SQLServer: "UNIQUEIDENTIFIER", PostgreSQL: "UUID",
+		SQLite: "TEXT", Oracle: "VARCHAR2(36)", CockroachDB: "UUID",
+	},
+}
+
+func getColumnTypeFor(portableType string, databaseType DatabaseType, args ...interface{}) (string, error) {
+	template, ok := columnTypeTemplates[portableType][databaseType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
+	}
+	return fmt.Sprintf(template, args...), nil
+}
+
+var tableExistsQueryTemplates = map[DatabaseType]string{
+	MySQL:       "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?;",
+	MariaDB:     "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?;",
+	SQLServer:   "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = 'dbo' AND table_name = @p1;",
+	PostgreSQL:  "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = 'public' AND table_name = $1;",
+	SQLite:      "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;",
+	Oracle:      "SELECT COUNT(*) FROM user_tables WHERE table_name = :1",
+	CockroachDB: "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = 'public' AND table_name = $1;",
+}
+
+func getQueryForTableExists(databaseType DatabaseType) (string, error) {
+	template, ok := tableExistsQueryTemplates[databaseType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
 	}
 	return template, nil
 }
 
 var dropTableQueryTemplates = map[DatabaseType]string{
-	MySQL:       "DROP TABLE `%s`;",
-	MariaDB:     "DROP TABLE `%s`;",
-	SQLServer:   "DROP TABLE [%s];",
-	PostgreSQL:  "DROP TABLE \"%s\";",
-	SQLite:      "DROP TABLE `%s`;",
-	Oracle:      "BEGIN EXECUTE IMMEDIATE 'DROP TABLE %s'; EXCEPTION WHEN OTHERS THEN IF SQLCODE != -942 THEN RAISE; END IF; END;",
-	CockroachDB: "DROP TABLE \"%s\";",
+	MySQL:       "DROP TABLE %s;",
+	MariaDB:     "DROP TABLE %s;",
+	SQLServer:   "DROP TABLE %s;",
+	PostgreSQL:  "DROP TABLE %s;",
+	SQLite:      "DROP TABLE %s;",
+	Oracle:      "DROP TABLE %s",
+	CockroachDB: "DROP TABLE %s;",
 }
 
 func getQueryForDeleteTable(databaseType DatabaseType) (string, error) {
 	template, ok := dropTableQueryTemplates[databaseType]
 	if !ok {
-		return "", fmt.Errorf("unsupported database type: %s", databaseType)
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
 	}
 	return template, nil
 }
 
+// Oracle has no DROP TABLE IF EXISTS.
+var dropTableIfExistsQueryTemplates = map[DatabaseType]string{
+	MySQL:       "DROP TABLE IF EXISTS %s;",
+	MariaDB:     "DROP TABLE IF EXISTS %s;",
+	SQLServer:   "DROP TABLE IF EXISTS %s;",
+	PostgreSQL:  "DROP TABLE IF EXISTS %s;",
+	SQLite:      "DROP TABLE IF EXISTS %s;",
+	CockroachDB: "DROP TABLE IF EXISTS %s;",
+}
+
+// Returns false when the dialect has no DROP TABLE IF EXISTS.
+func getQueryForDeleteTableIfExists(databaseType DatabaseType) (string, bool) {
+	template, ok := dropTableIfExistsQueryTemplates[databaseType]
+	return template, ok
+}
+
+// The dialects with CREATE TABLE IF NOT EXISTS.
+var createTableIfNotExistsDatabases = map[DatabaseType]bool{
+	MySQL:       true,
+	MariaDB:     true,
+	PostgreSQL:  true,
+	SQLite:      true,
+	CockroachDB: true,
+}
+
 var renameTableQueryTemplates = map[DatabaseType]string{
-	MySQL:       "RENAME TABLE `%s` TO `%s`;",
-	MariaDB:     "RENAME TABLE `%s` TO `%s`;",
-	SQLServer:   "EXEC sp_rename '%s', '%s';",
-	PostgreSQL:  "ALTER TABLE \"%s\" RENAME TO \"%s\";",
-	SQLite:      "ALTER TABLE `%s` RENAME TO `%s`;",
-	Oracle:      "ALTER TABLE %s RENAME TO %s;",
-	CockroachDB: "ALTER TABLE \"%s\" RENAME TO \"%s\";",
+	MySQL:       "RENAME TABLE %s TO %s;",
+	MariaDB:     "RENAME TABLE %s TO %s;",
+	SQLServer:   "EXEC sp_rename @p1, @p2;", // takes the names as parameters instead
+	PostgreSQL:  "ALTER TABLE %s RENAME TO %s;",
+	SQLite:      "ALTER TABLE %s RENAME TO %s;",
+	Oracle:      "ALTER TABLE %s RENAME TO %s",
+	CockroachDB: "ALTER TABLE %s RENAME TO %s;",
 }
 
 func getQueryForRenameTable(databaseType DatabaseType) (string, error) {
 	template, ok := renameTableQueryTemplates[databaseType]
 	if !ok {
-		return "", fmt.Errorf("unsupported database type: %s", databaseType)
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
 	}
 	return template, nil
 }
 
 var duplicateCreateTableQueryTemplates = map[DatabaseType]string{
-	MySQL:       "CREATE TABLE `%s` LIKE `%s`;",
-	MariaDB:     "CREATE TABLE `%s` LIKE `%s`;",
+	MySQL:       "CREATE TABLE %s LIKE %s;",
+	MariaDB:     "CREATE TABLE %s LIKE %s;",
 	SQLServer:   "SELECT * INTO %s FROM %s WHERE 1 = 0;",
-	PostgreSQL:  "CREATE TABLE \"%s\" (LIKE \"%s\" INCLUDING ALL);",
-	SQLite:      "CREATE TABLE `%s` AS SELECT * FROM `%s` WHERE 1 = 0;",
-	Oracle:      "CREATE TABLE %s AS SELECT * FROM %s WHERE 1 = 0;",
-	CockroachDB: "CREATE TABLE \"%s\" (LIKE \"%s\" INCLUDING ALL);",
+	PostgreSQL:  "CREATE TABLE %s (LIKE %s INCLUDING ALL);",
+	SQLite:      "CREATE TABLE %s AS SELECT * FROM %s WHERE 1 = 0;",
+	Oracle:      "CREATE TABLE %s AS SELECT * FROM %s WHERE 1 = 0",
+	CockroachDB: "CREATE TABLE %s (LIKE %s INCLUDING ALL);",
 }
 
 func getQueryForDuplicateTableCreate(databaseType DatabaseType) (string, error) {
 	template, ok := duplicateCreateTableQueryTemplates[databaseType]
 	if !ok {
-		return "", fmt.Errorf("unsupported database type: %s", databaseType)
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
 	}
 	return template, nil
 }
 
 var duplicateInsertDataQueryTemplates = map[DatabaseType]string{
-	MySQL:       "INSERT INTO `%s` SELECT * FROM `%s`;",
-	MariaDB:     "INSERT INTO `%s` SELECT * FROM `%s`;",
+	MySQL:       "INSERT INTO %s SELECT * FROM %s;",
+	MariaDB:     "INSERT INTO %s SELECT * FROM %s;",
 	SQLServer:   "INSERT INTO %s SELECT * FROM %s;",
-	PostgreSQL:  "INSERT INTO \"%s\" SELECT * FROM \"%s\";",
-	SQLite:      "INSERT INTO `%s` SELECT * FROM `%s`;",
-	Oracle:      "INSERT INTO %s SELECT * FROM %s;",
-	CockroachDB: "INSERT INTO \"%s\" SELECT * FROM \"%s\";",
+	PostgreSQL:  "INSERT INTO %s SELECT * FROM %s;",
+	SQLite:      "INSERT INTO %s SELECT * FROM %s;",
+	Oracle:      "INSERT INTO %s SELECT * FROM %s",
+	CockroachDB: "INSERT INTO %s SELECT * FROM %s;",
 }
 
 func getQueryForDuplicateTableInsert(databaseType DatabaseType) (string, error) {
 	template, ok := duplicateInsertDataQueryTemplates[databaseType]
 	if !ok {
-		return "", fmt.Errorf("unsupported database type: %s", databaseType)
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
+	}
+	return template, nil
+}
+
+// Clauses such as RESTART IDENTITY or CASCADE are appended by the caller.
+var truncateTableQueryTemplates = map[DatabaseType]string{
+	MySQL:       "TRUNCATE TABLE %s",
+	MariaDB:     "TRUNCATE TABLE %s",
+	SQLServer:   "TRUNCATE TABLE %s",
+	PostgreSQL:  "TRUNCATE TABLE %s",
+	Oracle:      "TRUNCATE TABLE %s",
+	CockroachDB: "TRUNCATE TABLE %s",
+}
+
+func getQueryForTruncateTable(databaseType DatabaseType) (string, error) {
+	template, ok := truncateTableQueryTemplates[databaseType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
 	}
 	return template, nil
 }
 
-func getConnectionString(connInfo *DBConnection) (string, error) {
+var deleteAllRecordsQueryTemplates = map[DatabaseType]string{
+	MySQL:       "DELETE FROM %s",
+	MariaDB:     "DELETE FROM %s",
+	SQLServer:   "DELETE FROM %s",
+	PostgreSQL:  "DELETE FROM %s",
+	SQLite:      "DELETE FROM %s",
+	Oracle:      "DELETE FROM %s",
+	CockroachDB: "DELETE FROM %s",
+}
+
+func getQueryForDeleteAllRecords(databaseType DatabaseType) (string, error) {
+	template, ok := deleteAllRecordsQueryTemplates[databaseType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
+	}
+	return template, nil
+}
+
+// Selects and locks rows until the end of the transaction, SQLite locks the
+// whole database on the first write instead.
+var lockRowsQueryTemplates = map[DatabaseType]string{
+	MySQL:       "SELECT * FROM %s WHERE %s FOR UPDATE",
+	MariaDB:     "SELECT * FROM %s WHERE %s FOR UPDATE",
+	SQLServer:   "SELECT * FROM %s WITH (UPDLOCK, ROWLOCK) WHERE %s",
+	PostgreSQL:  "SELECT * FROM %s WHERE %s FOR UPDATE",
+	SQLite:      "SELECT * FROM %s WHERE %s",
+	Oracle:      "SELECT * FROM %s WHERE %s FOR UPDATE",
+	CockroachDB: "SELECT * FROM %s WHERE %s FOR UPDATE",
+}
+
+func getQueryForLockRows(databaseType DatabaseType) (string, error) {
+	template, ok := lockRowsQueryTemplates[databaseType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
+	}
+	return template, nil
+}
+
+// Columns holding JSON that the result column types do not reveal: JSON in
+// text columns checked by ISJSON (SQL Server), IS JSON (Oracle) or
+// JSON_VALID (MariaDB).
+var jsonColumnsQueryTemplates = map[DatabaseType]string{
+	MariaDB:   "SELECT c.COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS c WHERE c.TABLE_SCHEMA = DATABASE() AND c.TABLE_NAME = ? AND (c.DATA_TYPE = 'json' OR EXISTS (SELECT 1 FROM INFORMATION_SCHEMA.CHECK_CONSTRAINTS cc WHERE cc.CONSTRAINT_SCHEMA = c.TABLE_SCHEMA AND cc.TABLE_NAME = c.TABLE_NAME AND cc.CHECK_CLAUSE LIKE CONCAT('json_valid(`', c.COLUMN_NAME, '`)')))",
+	SQLServer: "SELECT c.name FROM sys.columns c WHERE c.object_id = OBJECT_ID(@p1) AND (TYPE_NAME(c.system_type_id) = 'json' OR EXISTS (SELECT 1 FROM sys.check_constraints cc WHERE cc.parent_object_id = c.object_id AND cc.parent_column_id = c.column_id AND LOWER(cc.definition) LIKE '%isjson%'))",
+	Oracle:    "SELECT column_name FROM user_json_columns WHERE table_name = :1",
+}
+
+// ok is false for the dialects where the type of the result columns is enough.
+func getQueryForJSONColumns(databaseType DatabaseType) (string, bool) {
+	template, ok := jsonColumnsQueryTemplates[databaseType]
+	return template, ok
+}
+
+// Columns holding booleans that the result column types do not reveal: MySQL
+// and MariaDB store BOOLEAN as TINYINT(1) and the driver reports TINYINT
+// without its width.
+var boolColumnsQueryTemplates = map[DatabaseType]string{
+	MySQL:   "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_TYPE LIKE 'tinyint(1)%'",
+	MariaDB: "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_TYPE LIKE 'tinyint(1)%'",
+}
+
+// ok is false for the dialects where the type of the result columns is enough.
+func getQueryForBoolColumns(databaseType DatabaseType) (string, bool) {
+	template, ok := boolColumnsQueryTemplates[databaseType]
+	return template, ok
+}
+
+// Extracts the value at a JSON path as text, from the column and the path
+// arguments.
+var jsonExtractQueryTemplates = map[DatabaseType]string{
+	MySQL:       "JSON_UNQUOTE(JSON_EXTRACT(%s, %s))",
+	MariaDB:     "JSON_UNQUOTE(JSON_EXTRACT(%s, %s))",
+	SQLServer:   "JSON_VALUE(%s, %s)",
+	PostgreSQL:  "jsonb_extract_path_text(%s::jsonb, %s)",
+	SQLite:      "json_extract(%s, %s)",
+	Oracle:      "JSON_VALUE(%s, %s)",
+	CockroachDB: "jsonb_extract_path_text(%s::jsonb, %s)",
+}
+
+func getQueryForJSONExtract(databaseType DatabaseType) (string, error) {
+	template, ok := jsonExtractQueryTemplates[databaseType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
+	}
+	return template, nil
+}
+
+var auditTableQueryTemplates = map[DatabaseType]string{
+	MySQL:       "CREATE TABLE %s (id BIGINT AUTO_INCREMENT PRIMARY KEY, occurred_at DATETIME(6) NOT NULL, actor VARCHAR(255), operation VARCHAR(64) NOT NULL, table_name VARCHAR(255) NOT NULL, primary_key TEXT, before_values LONGTEXT, after_values LONGTEXT, details TEXT)",
+	MariaDB:     "CREATE TABLE %s (id BIGINT AUTO_INCREMENT PRIMARY KEY, occurred_at DATETIME(6) NOT NULL, actor VARCHAR(255), operation VARCHAR(64) NOT NULL, table_name VARCHAR(255) NOT NULL, primary_key TEXT, before_values LONGTEXT, after_values LONGTEXT, details TEXT)",
+	SQLServer:   "CREATE TABLE %s (id BIGINT IDENTITY(1,1) PRIMARY KEY, occurred_at DATETIMEOFFSET NOT NULL, actor NVARCHAR(255), operation NVARCHAR(64) NOT NULL, table_name NVARCHAR(255) NOT NULL, primary_key NVARCHAR(MAX), before_values NVARCHAR(MAX), after_values NVARCHAR(MAX), details NVARCHAR(MAX))",
+	PostgreSQL:  "CREATE TABLE %s (id BIGSERIAL PRIMARY KEY, occurred_at TIMESTAMPTZ NOT NULL, actor TEXT, operation TEXT NOT NULL, table_name TEXT NOT NULL, primary_key TEXT, before_values TEXT, after_values TEXT, details TEXT)",
+	SQLite:      "CREATE TABLE %s (id INTEGER PRIMARY KEY AUTOINCREMENT, occurred_at TIMESTAMP NOT NULL, actor TEXT, operation TEXT NOT NULL, table_name TEXT NOT NULL, primary_key TEXT, before_values TEXT, after_values TEXT, details TEXT)",
+	Oracle:      "CREATE TABLE %s (id NUMBER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, occurred_at TIMESTAMP WITH TIME ZONE NOT NULL, actor VARCHAR2(255), operation VARCHAR2(64) NOT NULL, table_name VARCHAR2(255) NOT NULL, primary_key CLOB, before_values CLOB, after_values CLOB, details CLOB)",
+	CockroachDB: "CREATE TABLE %s (id INT8 DEFAULT unique_rowid() PRIMARY KEY, occurred_at TIMESTAMPTZ NOT NULL, actor STRING, operation STRING NOT NULL, table_name STRING NOT NULL, primary_key STRING, before_values STRING, after_values STRING, details STRING)",
+}
+
+func getQueryForAuditTable(databaseType DatabaseType) (string, error) {
+	template, ok := auditTableQueryTemplates[databaseType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
+	}
+	return template, nil
+}
+
+// Inserts a row or updates the one with the same primary key. %[1]s table,
+// %[2]s columns, %[3]s placeholders, %[4]s primary key columns, %[5]s update
+// clause, and for MERGE %[6]s the aliased placeholders, %[7]s the primary key
+// join and %[8]s the source columns.
+var upsertQueryTemplates = map[DatabaseType]string{
+	MySQL:       "INSERT INTO %[1]s (%[2]s) VALUES (%[3]s)%[5]s",
+	MariaDB:     "INSERT INTO %[1]s (%[2]s) VALUES (%[3]s)%[5]s",
+	SQLServer:   "MERGE INTO dbo.%[1]s AS t USING (SELECT %[6]s) AS s ON (%[7]s)%[5]s WHEN NOT MATCHED THEN INSERT (%[2]s) VALUES (%[8]s);",
+	PostgreSQL:  "INSERT INTO %[1]s (%[2]s) VALUES (%[3]s) ON CONFLICT (%[4]s)%[5]s",
+	SQLite:      "INSERT INTO %[1]s (%[2]s) VALUES (%[3]s) ON CONFLICT (%[4]s)%[5]s",
+	Oracle:      "MERGE INTO %[1]s t USING (SELECT %[6]s FROM dual) s ON (%[7]s)%[5]s WHEN NOT MATCHED THEN INSERT (%[2]s) VALUES (%[8]s)",
+	CockroachDB: "INSERT INTO %[1]s (%[2]s) VALUES (%[3]s) ON CONFLICT (%[4]s)%[5]s",
+}
+
+var upsertUpdateClauseTemplates = map[DatabaseType]string{
+	MySQL:       " ON DUPLICATE KEY UPDATE %s",
+	MariaDB:     " ON DUPLICATE KEY UPDATE %s",
+	SQLServer:   " WHEN MATCHED THEN UPDATE SET %s",
+	PostgreSQL:  " DO UPDATE SET %s",
+	SQLite:      " DO UPDATE SET %s",
+	Oracle:      " WHEN MATCHED THEN UPDATE SET %s",
+	CockroachDB: " DO UPDATE SET %s",
+}
+
+var upsertAssignmentTemplates = map[DatabaseType]string{
+	MySQL:       "%[1]s = VALUES(%[1]s)",
+	MariaDB:     "%[1]s = VALUES(%[1]s)",
+	SQLServer:   "t.%[1]s = s.%[1]s",
+	PostgreSQL:  "%[1]s = EXCLUDED.%[1]s",
+	SQLite:      "%[1]s = excluded.%[1]s",
+	Oracle:      "t.%[1]s = s.%[1]s",
+	CockroachDB: "%[1]s = EXCLUDED.%[1]s",
+}
+
+// Returns the statement inserting the columns, or updating the other columns
+// of the row with the same primary key. Placeholders are numbered from 1.
+func getQueryForUpsert(tableName string, columns, primaryKeys []string, databaseType DatabaseType) (string, error) {
+	template, ok := upsertQueryTemplates[databaseType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
+	}
+
+	quotedTableName, err := quoteIdentifier(tableName, databaseType)
+	if err != nil {
+		return "", err
+	}
+	quotedColumns, err := quoteIdentifiers(columns, databaseType)
+	if err != nil {
+		return "", err
+	}
+	quotedPrimaryKeys, err := quoteIdentifiers(primaryKeys, databaseType)
+	if err != nil {
+		return "", err
+	}
+	placeholders, err := getPlaceholders(databaseType, 1, len(columns))
+	if err != nil {
+		return "", err
+	}
+
+	isPrimaryKey := make(map[string]bool, len(primaryKeys))
+	for _, key := range quotedPrimaryKeys {
+		isPrimaryKey[key] = true
+	}
+
+	var assignments []string
+	for _, column := range quotedColumns {
+		if !isPrimaryKey[column] {
+			assignments = append(assignments, fmt.Sprintf(upsertAssignmentTemplates[databaseType], column))
+		}
+	}
+
+	var updateClause string
+	switch {
+	case len(assignments) != 0:
+		updateClause = fmt.Sprintf(upsertUpdateClauseTemplates[databaseType], strings.Join(assignments, ", "))
+	case databaseType == MySQL || databaseType == MariaDB:
+		// nothing to update, assign the key to itself to ignore the row
+		updateClause = fmt.Sprintf(upsertUpdateClauseTemplates[databaseType], fmt.Sprintf("%[1]s = %[1]s", quotedPrimaryKeys[0]))
+	case databaseType == PostgreSQL || databaseType == SQLite || databaseType == CockroachDB:
+		updateClause = " DO NOTHING"
+	}
+
+	aliased := make([]string, len(columns))
+	sourceColumns := make([]string, len(columns))
+	for index, column := range quotedColumns {
+		aliased[index] = placeholders[index] + " AS " + column
+		sourceColumns[index] = "s." + column
+	}
+	joins := make([]string, len(quotedPrimaryKeys))
+	for index, key := range quotedPrimaryKeys {
+		joins[index] = fmt.Sprintf("t.%[1]s = s.%[1]s", key)
+	}
+
+	return fmt.Sprintf(template,
+		quotedTableName,
+		strings.Join(quotedColumns, ", "),
+		strings.Join(placeholders, ", "),
+		strings.Join(quotedPrimaryKeys, ", "),
+		updateClause,
+		strings.Join(aliased, ", "),
+		strings.Join(joins, " AND "),
+		strings.Join(sourceColumns, ", "),
+	), nil
+}
+
+// Orders a query by the columns and keeps the first rows.
+var pageQueryTemplates = map[DatabaseType]string{
+	MySQL:       "%s ORDER BY %s LIMIT %d",
+	MariaDB:     "%s ORDER BY %s LIMIT %d",
+	SQLServer:   "%s ORDER BY %s OFFSET 0 ROWS FETCH NEXT %d ROWS ONLY",
+	PostgreSQL:  "%s ORDER BY %s LIMIT %d",
+	SQLite:      "%s ORDER BY %s LIMIT %d",
+	Oracle:      "%s ORDER BY %s OFFSET 0 ROWS FETCH NEXT %d ROWS ONLY",
+	CockroachDB: "%s ORDER BY %s LIMIT %d",
+}
+
+// Compares text in the byte order of its UTF-8 encoding, the order Go compares
+// strings in, whatever the collation of the column. CockroachDB compares
+// strings that way already.
+var binaryCollationTemplates = map[DatabaseType]string{
+	MySQL:      "CAST(CONVERT(%s USING utf8mb4) AS BINARY)",
+	MariaDB:    "CAST(CONVERT(%s USING utf8mb4) AS BINARY)",
+	SQLServer:  "%s COLLATE Latin1_General_BIN2",
+	PostgreSQL: "%s COLLATE \"C\"",
+	SQLite:     "%s COLLATE BINARY",
+	Oracle:     "NLSSORT(%s, 'NLS_SORT=BINARY')",
+}
+
+// Wraps the text expression, a column or a placeholder, to be compared and
+// sorted in binary order.
+func collateBinary(expression string, databaseType DatabaseType) string {
+	template, ok := binaryCollationTemplates[databaseType]
+	if !ok {
+		return expression
+	}
+	return fmt.Sprintf(template, expression)
+}
+
+// The order by columns are expected quoted already.
+func getQueryForPage(query string, orderBy []string, limit int, databaseType DatabaseType) (string, error) {
+	template, ok := pageQueryTemplates[databaseType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, databaseType)
+	}
+	return fmt.Sprintf(template, query, strings.Join(orderBy, ", "), limit), nil
+}
+
+// Counts and hashes the rows of a table from the row text, the columns
+// joined, and the table. PostgreSQL, CockroachDB and SQL Server also take the
+// primary key order to aggregate in. Oracle sums the first 60 bits of the
+// row hashes.
+var checksumQueryTemplates = map[DatabaseType]string{
+	MySQL:       "SELECT COUNT(*), COALESCE(BIT_XOR(CAST(CONV(SUBSTRING(MD5(%[1]s), 1, 16), 16, 10) AS UNSIGNED)), 0) FROM %[2]s",
+	MariaDB:     "SELECT COUNT(*), COALESCE(BIT_XOR(CAST(CONV(SUBSTRING(MD5(%[1]s), 1, 16), 16, 10) AS UNSIGNED)), 0) FROM %[2]s",
+	SQLServer:   "SELECT COUNT(*), COALESCE(CONVERT(VARCHAR(64), HASHBYTES('SHA2_256', STRING_AGG(CAST(CONVERT(VARCHAR(64), HASHBYTES('SHA2_256', %[1]s), 2) AS VARCHAR(MAX)), '') WITHIN GROUP (ORDER BY %[3]s)), 2), '') FROM dbo.%[2]s",
+	PostgreSQL:  "SELECT COUNT(*), COALESCE(md5(string_agg(md5(%[1]s), '' ORDER BY %[3]s)), '') FROM %[2]s",
+	Oracle:      "SELECT COUNT(*), COALESCE(SUM(TO_NUMBER(SUBSTR(%[1]s, 1, 15), 'XXXXXXXXXXXXXXX')), 0) FROM %[2]s",
+	CockroachDB: "SELECT COUNT(*), COALESCE(md5(string_agg(md5(%[1]s), '' ORDER BY %[3]s)), '') FROM \"public\".%[2]s",
+}
+
+// Turns the columns into the row text hashed by the checksum. Each value is
+// preceded by its length and a colon, NULL being a lone "-", so that no two
+// rows give the same text; SQL Server does the same with the bytes of the
+// values. Oracle hashes each value instead and, to stay within the VARCHAR2
+// limit, the hashes of every group of columns.
+var checksumRowTemplates = map[DatabaseType]struct {
+	column, separator, row string
+	group                  int
+}{
+	MySQL:       {"COALESCE(CONCAT(CHAR_LENGTH(CAST(%[1]s AS CHAR)), ':', CAST(%[1]s AS CHAR)), '-')", ", ", "CONCAT(%s)", 0},
+	MariaDB:     {"COALESCE(CONCAT(CHAR_LENGTH(CAST(%[1]s AS CHAR)), ':', CAST(%[1]s AS CHAR)), '-')", ", ", "CONCAT(%s)", 0},
+	SQLServer:   {"COALESCE(CAST(DATALENGTH(%[1]s) AS BINARY(4)) + CAST(%[1]s AS VARBINARY(MAX)), 0xFFFFFFFF)", " + ", "%s", 0},
+	PostgreSQL:  {"COALESCE(CAST(length(CAST(%[1]s AS TEXT)) AS TEXT) || ':' || CAST(%[1]s AS TEXT), '-')", ", ", "concat(%s)", 0},
+	Oracle:      {"NVL(RAWTOHEX(STANDARD_HASH(%s, 'MD5')), '-')", " || ", "RAWTOHEX(STANDARD_HASH(%s, 'MD5'))", 100},
+	CockroachDB: {"COALESCE(CAST(length(CAST(%[1]s AS STRING)) AS STRING) || ':' || CAST(%[1]s AS STRING), '-')", ", ", "concat(%s)", 0},
+}
+
+// ok is false for the dialects without hash functions, SQLite, whose rows
+// are hashed by the caller. The order by expressions are expected quoted
+// already.
+func getQueryForChecksum(tableName string, columns, orderBy []string, databaseType DatabaseType) (string, bool, error) {
+	template, ok := checksumQueryTemplates[databaseType]
+	if !ok {
+		return "", false, nil
+	}
+
+	quotedTableName, err := quoteIdentifier(tableName, databaseType)
+	if err != nil {
+		return "", false, err
+	}
+	quotedColumns, err := quoteIdentifiers(columns, databaseType)
+	if err != nil {
+		return "", false, err
+	}
+	row := checksumRowTemplates[databaseType]
+	values := make([]string, len(quotedColumns))
+	for index, column := range quotedColumns {
+		values[index] = fmt.Sprintf(row.column, column)
+	}
+	for row.group > 0 && len(values) > row.group {
+		groups := make([]string, 0, (len(values)+row.group-1)/row.group)
+		for start := 0; start < len(values); start += row.group {
+			end := start + row.group
+			if end > len(values) {
+				end = len(values)
+			}
+			groups = append(groups, fmt.Sprintf(row.row, strings.Join(values[start:end], row.separator)))
+		}
+		values = groups
+	}
+
+	return fmt.Sprintf(template,
+		fmt.Sprintf(row.row, strings.Join(values, row.separator)),
+		quotedTableName,
+		strings.Join(orderBy, ", "),
+	), true, nil
+}
+
+// A read-only connection string makes the server reject writes, for the
+// drivers that allow it.
+func getConnectionString(connInfo *DBConnection, readOnly bool) (string, error) {
 	switch connInfo.Type {
 	case PostgreSQL:
-		return fmt.Sprintf(
+		connStr := fmt.Sprintf(
 			"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 			connInfo.Host, connInfo.Port, connInfo.User, connInfo.Pass, connInfo.Name,
-		), nil
+		)
+		if readOnly {
+			connStr += " default_transaction_read_only=on"
+		}
+		return connStr, nil
 	case MySQL:
-		return fmt.Sprintf(
+		connStr := fmt.Sprintf(
 			"%s:%s@tcp(%s:%s)/%s",
 			connInfo.User, connInfo.Pass, connInfo.Host, connInfo.Port, connInfo.Name,
-		), nil
+		)
+		if readOnly {
+			connStr += "?transaction_read_only=1"
+		}
+		return connStr, nil
 	case SQLite:
+		if readOnly {
+			// a shared cache would let the connection piggyback on a writable one
+			return fmt.Sprintf("file:%s?mode=ro", connInfo.Host), nil
+		}
 		return fmt.Sprintf("file:%s?cache=shared&mode=rwc", connInfo.Host), nil
 	case SQLServer:
 		return fmt.Sprintf(
@@ -193,11 +838,15 @@ func getConnectionString(connInfo *DBConnection) (string, error) {
 			connInfo.User, connInfo.Pass, connInfo.Host, connInfo.Name,
 		), nil
 	case CockroachDB:
-		return fmt.Sprintf(
+		connStr := fmt.Sprintf(
 			"postgresql://%s:%s@%s:%s/%s?sslmode=disable",
 			connInfo.User, connInfo.Pass, connInfo.Host, connInfo.Port, connInfo.Name,
-		), nil
+		)
+		if readOnly {
+			connStr += "&default_transaction_read_only=on"
+		}
+		return connStr, nil
 	default:
-		return "", fmt.Errorf("unsupported database type: %s", connInfo.Type)
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDatabase, connInfo.Type)
 	}
 }
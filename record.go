@@ -3,39 +3,134 @@ package sqlutils
 import (
 	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
 )
 
-// Extracts the keys and the values from the record
+// Extracts the keys and the values from the record, ordered by key so the
+// generated SQL is stable
 func extractRecordData(record TableRecord) ([]string, []interface{}) {
-	var recordKeys = make([]string, len(record))
-	var recordValues = make([]interface{}, len(record))
-	var index = 0
+	var recordKeys = make([]string, 0, len(record))
+	for key := range record {
+		recordKeys = append(recordKeys, key)
+	}
+	sort.Strings(recordKeys)
 
-	for key, value := range record {
-		recordKeys[index] = key
-		recordValues[index] = value
-		index += 1
+	var recordValues = make([]interface{}, len(recordKeys))
+	for index, key := range recordKeys {
+		recordValues[index] = record[key]
 	}
 
 	return recordKeys, recordValues
 }
 
-// Example return: order_id = ? AND customer_number = ?
-func computeConditions(keys []string, databaseType DatabaseType, placeholder string) string {
-	conditions := make([]string, len(keys))
+// Example return: "order_id" = ? AND "customer_number" = ?
+// Placeholders are numbered from the given 1-based position on.
+func computeConditions(keys []string, databaseType DatabaseType, position int) (string, error) {
+	conditions, err := computeComparisons(keys, databaseType, position)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(conditions, " AND "), nil
+}
+
+// Example return: "order_id" = ?, "customer_number" = ?
+func computeAssignments(keys []string, databaseType DatabaseType, position int) (string, error) {
+	assignments, err := computeComparisons(keys, databaseType, position)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(assignments, ", "), nil
+}
+
+func computeComparisons(keys []string, databaseType DatabaseType, position int) ([]string, error) {
+	comparisons := make([]string, len(keys))
 	for index, key := range keys {
-		local_placeholder := placeholder
+		placeholder, err := getPlaceholder(databaseType, position+index)
+		if err != nil {
+			return nil, err
+		}
+
+		quotedKey, err := quoteIdentifier(key, databaseType)
+		if err != nil {
+			return nil, err
+		}
+
+		comparisons[index] = fmt.Sprintf("%s = %s", quotedKey, placeholder)
+	}
+
+	return comparisons, nil
+}
+
+// Decoding of the rows read before and after a change, so that they write
+// back as they were: times stay time.Time and decimals keep their digits.
+var imageValueCodec = &ValueCodec{DecimalAsString: true}
 
-		switch databaseType {
-		case PostgreSQL, SQLServer, Oracle:
-			local_placeholder = fmt.Sprintf("%s%d", local_placeholder, index)
+// Reads and locks the rows matching the conditions, the rows a change is
+// about to affect. Run it in the transaction of the change.
+func selectRecords(
+	e execer,
+	o *options,
+	quotedTableName, conditions string,
+	databaseType DatabaseType,
+	args ...interface{},
+) ([]TableRecord, error) {
+	queryTemplate, err := getQueryForLockRows(databaseType)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(queryTemplate, quotedTableName, conditions)
+
+	rows, err := queryRows(e, o, query, args...)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	decodings := getColumnDecodings(columnTypes)
+
+	var records []TableRecord
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range columns {
+			valuePtrs[i] = &values[i]
 		}
 
-		conditions[index] = fmt.Sprintf("%s = %s", key, local_placeholder)
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+
+		record := make(TableRecord, len(columns))
+		for i, column := range columns {
+			// the binary values stay []byte whatever the options of the call
+			record[column], err = decodeColumnValue(values[i], decodings[i], imageValueCodec, &options{})
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", column, err)
+			}
+		}
+		records = append(records, record)
 	}
 
-	return strings.Join(conditions, " AND ")
+	return records, rows.Err()
+}
+
+func copyRecord(record TableRecord) TableRecord {
+	copied := make(TableRecord, len(record))
+	for key, value := range record {
+		copied[key] = value
+	}
+	return copied
 }
 
 func InsertRecord(
@@ -43,44 +138,116 @@ func InsertRecord(
 	tableName string,
 	record TableRecord,
 	databaseType DatabaseType,
+	opts ...Option,
 ) (int64, error) {
+	o := newOptions(db, OpInsertRecord, tableName, opts)
+
+	if err := checkGuard(o, tableName); err != nil {
+		return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
+	}
+
+	record, err := validateRecordColumns(db, o, tableName, record, databaseType)
+	if err != nil {
+		return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
+	}
+
 	recordKeys, recordValues := extractRecordData(record)
+	recordValues, err = encodeValues(o, recordValues)
+	if err != nil {
+		return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
+	}
 
-	quoteChar, ok := quoteCharMap[databaseType]
-	if !ok {
-		return 0, fmt.Errorf("%s - database type not supported", getCurrentFuncName())
+	quotedTableName, err := quoteIdentifier(tableName, databaseType)
+	if err != nil {
+		return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
 	}
-	placeholder, ok := placeholderMap[databaseType]
-	if !ok {
-		return 0, fmt.Errorf("%s - database type not supported", getCurrentFuncName())
+	quotedKeys, err := quoteIdentifiers(recordKeys, databaseType)
+	if err != nil {
+		return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
+	}
+	placeholders, err := getPlaceholders(databaseType, 1, len(recordValues))
+	if err != nil {
+		return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
 	}
 
-	repeatedPlaceholder := strings.Repeat(placeholder, len(recordValues))
-	repeatedPlaceholderArray := strings.Split(repeatedPlaceholder, "")
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quotedTableName,
+		strings.Join(quotedKeys, ", "),
+		strings.Join(placeholders, ", "),
+	)
 
-	switch databaseType {
-	case PostgreSQL, SQLServer, Oracle:
-		for index := range repeatedPlaceholderArray {
-			repeatedPlaceholderArray[index] = fmt.Sprintf("%s%d", repeatedPlaceholderArray[index], index)
+	var primaryKeys []string
+	if capturing(o) {
+		primaryKeys, err = GetPrimaryKeys(db, "", tableName, databaseType, inherit(o))
+		if err != nil {
+			return 0, fmt.Errorf("%s - error grabbing primary keys: %w", getCurrentFuncName(), err)
+		}
+		if err := checkUndoable(o, primaryKeys); err != nil {
+			return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
 		}
 	}
 
-	placeholders := strings.Join(repeatedPlaceholderArray, ", ")
+	var id int64
+	after := copyRecord(record)
 
-	query := fmt.Sprintf("INSERT INTO %s%s%s (%s) VALUES (%s)",
-		quoteChar, tableName, quoteChar,
-		strings.Join(recordKeys, ", "),
-		placeholders,
-	)
+	switch databaseType {
+	case PostgreSQL, CockroachDB:
+		// lib/pq has no LastInsertId, the generated keys are read back with RETURNING
+		if !capturing(o) || len(primaryKeys) == 0 {
+			if _, err := execStatement(db, o, query, recordValues...); err != nil {
+				return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), classifyError(err))
+			}
+			break
+		}
 
-	result, err := db.Exec(query, recordValues...)
-	if err != nil {
-		return 0, fmt.Errorf("%s - %v", getCurrentFuncName(), err)
+		quotedPrimaryKeys, err := quoteIdentifiers(primaryKeys, databaseType)
+		if err != nil {
+			return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
+		}
+		keyValues := make([]interface{}, len(primaryKeys))
+		dest := make([]interface{}, len(primaryKeys))
+		for index := range keyValues {
+			dest[index] = &keyValues[index]
+		}
+
+		query += " RETURNING " + strings.Join(quotedPrimaryKeys, ", ")
+		if err := queryRow(db, o, dest, query, recordValues...); err != nil {
+			return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), classifyError(err))
+		}
+
+		for index, key := range primaryKeys {
+			after[key] = keyValues[index]
+		}
+		if len(keyValues) == 1 {
+			id, _ = keyValues[0].(int64)
+		}
+	default:
+		result, err := execStatement(db, o, query, recordValues...)
+		if err != nil {
+			return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), classifyError(err))
+		}
+		if o.dryRun != nil {
+			return 0, nil
+		}
+
+		id, err = result.LastInsertId()
+		if err != nil {
+			return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), classifyError(err))
+		}
+
+		// the generated key is only known for single column keys
+		if len(primaryKeys) == 1 && id != 0 {
+			if _, ok := after[primaryKeys[0]]; !ok {
+				after[primaryKeys[0]] = id
+			}
+		}
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return 0, fmt.Errorf("%s - %v", getCurrentFuncName(), err)
+	if capturing(o) {
+		err = recordChanges(o, databaseType, primaryKeys, []RowChange{{After: after}})
+		if err != nil {
+			return id, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
+		}
 	}
 
 	return id, nil
@@ -92,13 +259,28 @@ func DuplicateRecord(
 	tableName string,
 	record TableRecord,
 	databaseType DatabaseType,
+	opts ...Option,
 ) error {
-	primaryKeys, err := GetPrimaryKeys(db, dbName, tableName, databaseType)
+	o := newOptions(db, OpDuplicateRecord, tableName, opts)
+
+	if err := checkGuard(o, tableName); err != nil {
+		return fmt.Errorf("%s - %w", getCurrentFuncName(), err)
+	}
+
+	record, err := validateRecordColumns(db, o, tableName, record, databaseType)
+	if err != nil {
+		return fmt.Errorf("%s - %w", getCurrentFuncName(), err)
+	}
+
+	primaryKeys, err := GetPrimaryKeys(db, dbName, tableName, databaseType, inherit(o))
 	if err != nil {
 		return fmt.Errorf("%s - error grabbing primary keys: %w", getCurrentFuncName(), err)
 	}
+	if err := checkUndoable(o, primaryKeys); err != nil {
+		return fmt.Errorf("%s - %w", getCurrentFuncName(), err)
+	}
 
-	columnTypes, err := getColumnTypes(db, dbName, tableName, databaseType)
+	columnTypes, err := getColumnTypes(db, o, dbName, tableName, databaseType)
 	if err != nil {
 		return fmt.Errorf("%s - error grabbing column types: %w", getCurrentFuncName(), err)
 	}
@@ -108,11 +290,16 @@ func DuplicateRecord(
 		record[key] = generateNewPrimaryKeyValue(dataType)
 	}
 
-	_, err = InsertRecord(db, tableName, record, databaseType)
+	_, err = InsertRecord(db, tableName, record, databaseType, inherit(o), withoutChangeTracking())
 	if err != nil {
 		return fmt.Errorf("%s - error inserting record: %w", getCurrentFuncName(), err)
 	}
 
+	err = recordChanges(o, databaseType, primaryKeys, []RowChange{{After: copyRecord(record)}})
+	if err != nil {
+		return fmt.Errorf("%s - %w", getCurrentFuncName(), err)
+	}
+
 	return nil
 }
 
@@ -123,49 +310,132 @@ func EditRecord(
 	updateColumn string,
 	updateValue any,
 	databaseType DatabaseType,
+	opts ...Option,
+) error {
+	o := newOptions(db, OpEditRecord, tableName, opts)
+
+	err := updateRecord(db, o, tableName, record, TableRecord{updateColumn: updateValue}, databaseType)
+	if err != nil {
+		return fmt.Errorf("%s - %w", getCurrentFuncName(), err)
+	}
+
+	return nil
+}
+
+// Sets the updated columns of the rows matching every value of the record.
+func updateRecord(
+	db *sql.DB,
+	o *options,
+	tableName string,
+	record TableRecord,
+	updates TableRecord,
+	databaseType DatabaseType,
 ) error {
-	quoteChar, ok := quoteCharMap[databaseType]
-	if !ok {
-		return fmt.Errorf("%s - database type not supported", getCurrentFuncName())
+	if err := checkGuard(o, tableName); err != nil {
+		return err
 	}
-	placeholder, ok := placeholderMap[databaseType]
-	if !ok {
-		return fmt.Errorf("%s - database type not supported", getCurrentFuncName())
+
+	updateColumns, updateValues := extractRecordData(updates)
+	if len(updateColumns) == 0 {
+		return fmt.Errorf("no columns to update")
+	}
+	updateValues, err := encodeValues(o, updateValues)
+	if err != nil {
+		return err
 	}
 
-	// also add identify by primary key like when removing
+	record, err = validateRecordColumns(db, o, tableName, record, databaseType, updateColumns...)
+	if err != nil {
+		return err
+	}
+	if len(record) == 0 {
+		return fmt.Errorf("record has no columns to identify the rows by")
+	}
 
-	switch databaseType {
-	case PostgreSQL, SQLServer, Oracle:
-		placeholder = fmt.Sprintf("%s%d", placeholder, 1)
+	quotedTableName, err := quoteIdentifier(tableName, databaseType)
+	if err != nil {
+		return err
+	}
+	assignments, err := computeAssignments(updateColumns, databaseType, 1)
+	if err != nil {
+		return err
 	}
 
+	// also add identify by primary key like when removing
+
 	recordKeys, recordValues := extractRecordData(record)
-	conditions := computeConditions(recordKeys, databaseType, placeholder)
+	recordValues, err = encodeValues(o, recordValues)
+	if err != nil {
+		return err
+	}
+	conditions, err := computeConditions(recordKeys, databaseType, len(updateValues)+1)
+	if err != nil {
+		return err
+	}
 
-	query := fmt.Sprintf("UPDATE %s%s%s SET %s = %s WHERE %s",
-		quoteChar, tableName, quoteChar,
-		updateColumn, placeholder,
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s",
+		quotedTableName,
+		assignments,
 		conditions,
 	)
 
-	args := append([]interface{}{updateValue}, recordValues...)
+	args := append(updateValues, recordValues...)
 
-	result, err := db.Exec(query, args...)
-	if err != nil {
-		return fmt.Errorf("%s - %v", getCurrentFuncName(), err)
+	var primaryKeys []string
+	if capturing(o) {
+		primaryKeys, err = GetPrimaryKeys(db, "", tableName, databaseType, inherit(o))
+		if err != nil {
+			return fmt.Errorf("error grabbing primary keys: %w", err)
+		}
+		if err := checkUndoable(o, primaryKeys); err != nil {
+			return err
+		}
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("%s - could not get rows affected - %v", getCurrentFuncName(), err)
-	}
+	var changes []RowChange
+	err = inTransaction(db, o, capturing(o), func(e execer) error {
+		if capturing(o) {
+			selectConditions, err := computeConditions(recordKeys, databaseType, 1)
+			if err != nil {
+				return err
+			}
+			before, err := selectRecords(e, o, quotedTableName, selectConditions, databaseType, recordValues...)
+			if err != nil {
+				return fmt.Errorf("reading rows before the change: %w", err)
+			}
+			for _, row := range before {
+				after := copyRecord(row)
+				for column, value := range updates {
+					after[column] = value
+				}
+				changes = append(changes, RowChange{Before: row, After: after})
+			}
+		}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("%s - no rows were updated", getCurrentFuncName())
+		result, err := execStatement(e, o, query, args...)
+		if err != nil {
+			return classifyError(err)
+		}
+		if o.dryRun != nil {
+			return nil
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("could not get rows affected - %w", err)
+		}
+
+		if rowsAffected == 0 {
+			return fmt.Errorf("no rows were updated: %w", ErrNoRowsAffected)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return recordChanges(o, databaseType, primaryKeys, changes)
 }
 
 func RemoveRecord(
@@ -174,80 +444,109 @@ func RemoveRecord(
 	tableName string,
 	databaseType DatabaseType,
 	record TableRecord,
+	opts ...Option,
 ) (int64, error) {
-	primaryKeys, err := GetPrimaryKeys(db, dbName, tableName, databaseType)
-	if err != nil {
-		return 0, fmt.Errorf("%s - error grabbing primary keys: %w", getCurrentFuncName(), err)
+	o := newOptions(db, OpRemoveRecord, tableName, opts)
+
+	if err := checkGuard(o, tableName); err != nil {
+		return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
 	}
 
-	quoteChar, ok := quoteCharMap[databaseType]
-	if !ok {
-		return 0, fmt.Errorf("%s - database type not supported", getCurrentFuncName())
+	record, err := validateRecordColumns(db, o, tableName, record, databaseType)
+	if err != nil {
+		return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
 	}
-	placeholder, ok := placeholderMap[databaseType]
-	if !ok {
-		return 0, fmt.Errorf("%s - database type not supported", getCurrentFuncName())
+	if len(record) == 0 {
+		return 0, fmt.Errorf("%s - record has no columns to identify the rows by", getCurrentFuncName())
 	}
 
-	// remove by primary key if any available
-	if len(primaryKeys) != 0 {
-		firstPrimaryKey := primaryKeys[0]
+	primaryKeys, err := GetPrimaryKeys(db, dbName, tableName, databaseType, inherit(o))
+	if err != nil {
+		return 0, fmt.Errorf("%s - error grabbing primary keys: %w", getCurrentFuncName(), err)
+	}
+	if err := checkUndoable(o, primaryKeys); err != nil {
+		return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
+	}
 
-		primaryKeyValue, ok := record[firstPrimaryKey]
-		if !ok {
-			return 0, fmt.Errorf("%s - primary key not provided", getCurrentFuncName())
-		}
+	quotedTableName, err := quoteIdentifier(tableName, databaseType)
+	if err != nil {
+		return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
+	}
+	var conditions string
+	var args []interface{}
 
-		switch databaseType {
-		case PostgreSQL, SQLServer, Oracle:
-			placeholder = fmt.Sprintf("%s%d", placeholder, 1)
+	// remove by primary key if any available, every column of it is needed
+	if len(primaryKeys) != 0 {
+		args = make([]interface{}, len(primaryKeys))
+		for index, primaryKey := range primaryKeys {
+			primaryKeyValue, ok := record[primaryKey]
+			if !ok {
+				return 0, fmt.Errorf("%s - %w: %s", getCurrentFuncName(), ErrPrimaryKeyMissing, primaryKey)
+			}
+			args[index] = primaryKeyValue
 		}
 
-		query := fmt.Sprintf("DELETE FROM %s%s%s WHERE %s%s%s = %s",
-			quoteChar, tableName, quoteChar,
-			quoteChar, firstPrimaryKey, quoteChar,
-			placeholder,
-		)
-
-		result, err := db.Exec(query, primaryKeyValue)
+		conditions, err = computeConditions(primaryKeys, databaseType, 1)
 		if err != nil {
-			return 0, err
+			return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
 		}
-
-		rowsAffected, err := result.RowsAffected()
+	} else {
+		var recordKeys []string
+		recordKeys, args = extractRecordData(record)
+		conditions, err = computeConditions(recordKeys, databaseType, 1)
 		if err != nil {
-			return 0, err
-		}
-
-		if rowsAffected == 0 {
-			return 0, fmt.Errorf("%s - record does not exist", getCurrentFuncName())
+			return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
 		}
-
-		return rowsAffected, nil
 	}
 
-	recordKeys, recordValues := extractRecordData(record)
-	conditions := computeConditions(recordKeys, databaseType, placeholder)
+	args, err = encodeValues(o, args)
+	if err != nil {
+		return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
+	}
 
-	query := fmt.Sprintf("DELETE FROM %s%s%s WHERE %s",
-		quoteChar, tableName, quoteChar,
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s",
+		quotedTableName,
 		conditions,
 	)
 
-	stmt, err := db.Prepare(query)
-	if err != nil {
-		return 0, err
-	}
-	defer stmt.Close()
+	var rowsAffected int64
+	var changes []RowChange
+	err = inTransaction(db, o, capturing(o), func(e execer) error {
+		if capturing(o) {
+			before, err := selectRecords(e, o, quotedTableName, conditions, databaseType, args...)
+			if err != nil {
+				return fmt.Errorf("reading rows before the change: %w", err)
+			}
+			for _, row := range before {
+				changes = append(changes, RowChange{Before: row})
+			}
+		}
 
-	result, err := stmt.Exec(recordValues...)
+		result, err := execStatement(e, o, query, args...)
+		if err != nil {
+			return classifyError(err)
+		}
+		if o.dryRun != nil {
+			return nil
+		}
+
+		rowsAffected, err = result.RowsAffected()
+		if err != nil {
+			return classifyError(err)
+		}
+
+		if rowsAffected == 0 && len(primaryKeys) != 0 {
+			return fmt.Errorf("record does not exist: %w", ErrNoRowsAffected)
+		}
+
+		return nil
+	})
 	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return 0, err
+	if err := recordChanges(o, databaseType, primaryKeys, changes); err != nil {
+		return rowsAffected, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
 	}
 
 	return rowsAffected, nil
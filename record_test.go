@@ -0,0 +1,78 @@
+package sqlutils
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestRemoveRecordCompositePrimaryKey(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer CloseDB(db)
+
+	_, err = db.Exec(`CREATE TABLE order_lines (
+		order_id INTEGER NOT NULL,
+		line INTEGER NOT NULL,
+		product TEXT,
+		PRIMARY KEY (order_id, line)
+	)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("INSERT INTO order_lines VALUES (1, 1, 'a'), (1, 2, 'b'), (2, 1, 'c')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type orderLine struct {
+		OrderID int64  `db:"order_id,pk"`
+		Line    int64  `db:"line,pk"`
+		Product string `db:"product"`
+	}
+
+	rowsAffected, err := DeleteStruct(db, "order_lines", orderLine{OrderID: 1, Line: 2}, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rowsAffected != 1 {
+		t.Fatalf("DeleteStruct removed %d rows, want 1", rowsAffected)
+	}
+
+	var remaining []orderLine
+	rows, err := db.Query("SELECT order_id, line, product FROM order_lines ORDER BY order_id, line")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line orderLine
+		if err := rows.Scan(&line.OrderID, &line.Line, &line.Product); err != nil {
+			t.Fatal(err)
+		}
+		remaining = append(remaining, line)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []orderLine{{1, 1, "a"}, {2, 1, "c"}}
+	if len(remaining) != len(want) {
+		t.Fatalf("remaining rows %v, want %v", remaining, want)
+	}
+	for index := range want {
+		if remaining[index] != want[index] {
+			t.Fatalf("remaining rows %v, want %v", remaining, want)
+		}
+	}
+
+	_, err = RemoveRecord(db, "", "order_lines", SQLite, TableRecord{"order_id": 1})
+	if !errors.Is(err, ErrPrimaryKeyMissing) {
+		t.Fatalf("RemoveRecord without every key column returned %v, want ErrPrimaryKeyMissing", err)
+	}
+}
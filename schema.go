@@ -0,0 +1,455 @@
+package sqlutils
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ColumnSchema describes a column of a table.
+type ColumnSchema struct {
+	Name string `json:"name"`
+	// Type is the database type, upper case and without size.
+	Type string `json:"type"`
+	// Length is the size of character and binary types, -1 for unbounded
+	// ones such as NVARCHAR(MAX).
+	Length int `json:"length,omitempty"`
+	// Precision and Scale of decimals, Precision is also the number of
+	// fractional digits of times.
+	Precision int  `json:"precision,omitempty"`
+	Scale     int  `json:"scale,omitempty"`
+	Nullable  bool `json:"nullable"`
+	// Values are the members of MySQL and MariaDB ENUM and SET types.
+	Values []string `json:"values,omitempty"`
+}
+
+// ForeignKey is a foreign key constraint of a table.
+type ForeignKey struct {
+	Name              string   `json:"name"`
+	Columns           []string `json:"columns"`
+	ReferencedTable   string   `json:"referenced_table"`
+	ReferencedColumns []string `json:"referenced_columns"`
+}
+
+// TableSchema describes a table, see DescribeTable.
+type TableSchema struct {
+	Name        string         `json:"name"`
+	Columns     []ColumnSchema `json:"columns"`
+	PrimaryKeys []string       `json:"primary_keys,omitempty"`
+	ForeignKeys []ForeignKey   `json:"foreign_keys,omitempty"`
+}
+
+// DescribeTable returns the columns, primary key and foreign keys of the
+// table.
+func DescribeTable(db *sql.DB, tableName string, dbType DatabaseType, opts ...Option) (TableSchema, error) {
+	o := newOptions(db, OpDescribeTable, tableName, opts)
+
+	if err := checkGuard(o, tableName); err != nil {
+		return TableSchema{}, fmt.Errorf("DescribeTable - %w", err)
+	}
+
+	if err := doesTableExist(db, o, tableName, dbType); err != nil {
+		return TableSchema{}, fmt.Errorf("DescribeTable - %w", err)
+	}
+
+	columns, err := getColumnSchemas(db, o, tableName, dbType)
+	if err != nil {
+		return TableSchema{}, fmt.Errorf("DescribeTable - %w", err)
+	}
+
+	primaryKeys, err := GetPrimaryKeys(db, "", tableName, dbType, inherit(o))
+	if err != nil {
+		return TableSchema{}, fmt.Errorf("DescribeTable - %w", err)
+	}
+
+	foreignKeys, err := GetForeignKeys(db, tableName, dbType, inherit(o))
+	if err != nil {
+		return TableSchema{}, fmt.Errorf("DescribeTable - %w", err)
+	}
+
+	return TableSchema{
+		Name:        tableName,
+		Columns:     columns,
+		PrimaryKeys: primaryKeys,
+		ForeignKeys: foreignKeys,
+	}, nil
+}
+
+// Sizes written in the type, as SQLite and Oracle report them:
+// VARCHAR(20), DECIMAL(10, 2), TIMESTAMP(6) WITH TIME ZONE.
+var typeSizePattern = regexp.MustCompile(`\s*\(([^)]*)\)`)
+
+func getColumnSchemas(db *sql.DB, o *options, tableName string, dbType DatabaseType) ([]ColumnSchema, error) {
+	query, err := getQueryForColumnSchema(dbType)
+	if err != nil {
+		return nil, fmt.Errorf("grabbing db type specific query: %w", err)
+	}
+
+	rows, err := queryRows(db, o, query, tableName)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+
+	var columns []ColumnSchema
+	for rows.Next() {
+		var column ColumnSchema
+		var length, precision, scale sql.NullInt64
+		var nullable string
+		var definition sql.NullString
+		if err := rows.Scan(&column.Name, &column.Type, &length, &precision, &scale, &nullable, &definition); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+
+		column.Nullable = strings.EqualFold(nullable, "YES")
+
+		sizes := typeSizePattern.FindStringSubmatch(column.Type)
+		column.Type = strings.ToUpper(strings.Join(strings.Fields(typeSizePattern.ReplaceAllString(column.Type, " ")), " "))
+		if sizedTypes[column.Type] {
+			column.Length = int(length.Int64)
+		}
+		switch columnKindsByTypeName[column.Type] {
+		case kindDecimal:
+			column.Precision = int(precision.Int64)
+			column.Scale = int(scale.Int64)
+		case kindTime:
+			column.Precision = int(precision.Int64)
+		}
+		if definition.Valid {
+			column.Values = parseEnumValues(definition.String)
+		}
+
+		if sizes != nil && column.Length == 0 && column.Precision == 0 {
+			parts := strings.Split(sizes[1], ",")
+			first, _ := strconv.Atoi(strings.TrimSpace(parts[0]))
+			switch {
+			case sizedTypes[column.Type]:
+				column.Length = first
+			case columnKindsByTypeName[column.Type] == kindDecimal:
+				column.Precision = first
+				if len(parts) == 2 {
+					column.Scale, _ = strconv.Atoi(strings.TrimSpace(parts[1]))
+				}
+			case columnKindsByTypeName[column.Type] == kindTime:
+				column.Precision = first
+			}
+		}
+
+		columns = append(columns, column)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+
+	return columns, nil
+}
+
+// Splits the members out of a MySQL ENUM or SET definition:
+// enum('a','it”s') gives a and it's.
+func parseEnumValues(definition string) []string {
+	start := strings.IndexByte(definition, '(')
+	end := strings.LastIndexByte(definition, ')')
+	if start == -1 || end < start {
+		return nil
+	}
+
+	var values []string
+	var value strings.Builder
+	quoted := false
+	members := definition[start+1 : end]
+	for i := 0; i < len(members); i++ {
+		c := members[i]
+		switch {
+		case c == '\'' && quoted && i+1 < len(members) && members[i+1] == '\'':
+			value.WriteByte(c)
+			i++
+		case c == '\'':
+			if quoted {
+				values = append(values, value.String())
+				value.Reset()
+			}
+			quoted = !quoted
+		case quoted:
+			value.WriteByte(c)
+		}
+	}
+	return values
+}
+
+// GetForeignKeys returns the foreign key constraints of the table.
+func GetForeignKeys(db *sql.DB, tableName string, dbType DatabaseType, opts ...Option) ([]ForeignKey, error) {
+	o := newOptions(db, OpGetForeignKeys, tableName, opts)
+
+	if err := checkGuard(o, tableName); err != nil {
+		return nil, fmt.Errorf("GetForeignKeys - %w", err)
+	}
+
+	query, err := getQueryForForeignKeys(dbType)
+	if err != nil {
+		return nil, fmt.Errorf("GetForeignKeys - grabbing db type specific query: %w", err)
+	}
+
+	rows, err := queryRows(db, o, query, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("GetForeignKeys - %w", classifyError(err))
+	}
+	defer rows.Close()
+
+	var foreignKeys []ForeignKey
+	for rows.Next() {
+		var name, column, referencedTable, referencedColumn string
+		if err := rows.Scan(&name, &column, &referencedTable, &referencedColumn); err != nil {
+			return nil, fmt.Errorf("GetForeignKeys - scanning row: %w", err)
+		}
+
+		last := len(foreignKeys) - 1
+		if last < 0 || foreignKeys[last].Name != name {
+			foreignKeys = append(foreignKeys, ForeignKey{Name: name, ReferencedTable: referencedTable})
+			last++
+		}
+		foreignKeys[last].Columns = append(foreignKeys[last].Columns, column)
+		foreignKeys[last].ReferencedColumns = append(foreignKeys[last].ReferencedColumns, referencedColumn)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetForeignKeys - rows iteration: %w", err)
+	}
+	rows.Close()
+
+	for i, foreignKey := range foreignKeys {
+		if foreignKey.ReferencedColumns[0] != "" {
+			continue
+		}
+		// SQLite leaves out the columns of references to the primary key
+		primaryKeys, err := GetPrimaryKeys(db, "", foreignKey.ReferencedTable, dbType, inherit(o))
+		if err != nil {
+			return nil, fmt.Errorf("GetForeignKeys - %w", err)
+		}
+		foreignKeys[i].ReferencedColumns = primaryKeys
+	}
+
+	return foreignKeys, nil
+}
+
+// Types taking a length.
+var sizedTypes = map[string]bool{
+	"CHAR": true, "VARCHAR": true, "NCHAR": true, "NVARCHAR": true, "VARCHAR2": true,
+	"NVARCHAR2": true, "BPCHAR": true, "CHARACTER": true, "BINARY": true, "VARBINARY": true,
+	"RAW": true,
+}
+
+// Types holding a time zone.
+var zonedTimeTypes = map[string]bool{
+	"TIMESTAMPTZ": true, "DATETIMEOFFSET": true, "TIMESTAMP WITH TIME ZONE": true,
+	"TIMESTAMP WITH LOCAL TIME ZONE": true,
+}
+
+// The decoding of the column, decimals without fraction being integers.
+func columnSchemaKind(column ColumnSchema) columnKind {
+	kind := columnKindsByTypeName[column.Type]
+	if kind == kindDecimal && column.Precision > 0 && column.Precision <= 18 && column.Scale == 0 {
+		return kindInteger
+	}
+	return kind
+}
+
+// Returns the type of the column in the target dialect, the type as it is
+// when the column stays in its dialect.
+func renderColumnType(column ColumnSchema, key bool, source, target DatabaseType) (string, error) {
+	if source == target {
+		switch {
+		case len(column.Values) != 0:
+			values := make([]string, len(column.Values))
+			for i, value := range column.Values {
+				values[i] = formatLiteral(value)
+			}
+			return fmt.Sprintf("%s(%s)", column.Type, strings.Join(values, ", ")), nil
+		case column.Precision > 0 && columnKindsByTypeName[column.Type] == kindTime:
+			// Oracle writes the digits before the zone: TIMESTAMP(6) WITH TIME ZONE
+			if strings.HasPrefix(column.Type, "TIMESTAMP ") {
+				return strings.Replace(column.Type, "TIMESTAMP", fmt.Sprintf("TIMESTAMP(%d)", column.Precision), 1), nil
+			}
+			return fmt.Sprintf("%s(%d)", column.Type, column.Precision), nil
+		case column.Length == -1:
+			return column.Type + "(MAX)", nil
+		case column.Length > 0 && sizedTypes[column.Type]:
+			return fmt.Sprintf("%s(%d)", column.Type, column.Length), nil
+		case column.Precision > 0 && columnKindsByTypeName[column.Type] == kindDecimal:
+			return fmt.Sprintf("%s(%d, %d)", column.Type, column.Precision, column.Scale), nil
+		default:
+			return column.Type, nil
+		}
+	}
+
+	switch columnSchemaKind(column) {
+	case kindBinary:
+		return getColumnTypeFor("binary", target)
+	case kindInteger:
+		return getColumnTypeFor("integer", target)
+	case kindFloat:
+		return getColumnTypeFor("float", target)
+	case kindDecimal:
+		if column.Precision > 0 {
+			return getColumnTypeFor("decimal", target, column.Precision, column.Scale)
+		}
+		return getColumnTypeFor("number", target)
+	case kindBool, kindBit:
+		return getColumnTypeFor("boolean", target)
+	case kindTime:
+		switch {
+		case column.Type == "DATE" && source != Oracle:
+			// Oracle dates carry the time of day
+			return getColumnTypeFor("date", target)
+		case column.Type == "TIME" || column.Type == "TIMETZ":
+			return getColumnTypeFor("time", target)
+		case zonedTimeTypes[column.Type]:
+			return getColumnTypeFor("timestamptz", target)
+		default:
+			return getColumnTypeFor("timestamp", target)
+		}
+	case kindJSON:
+		return getColumnTypeFor("json", target)
+	case kindUUID:
+		return getColumnTypeFor("uuid", target)
+	default:
+		switch {
+		case column.Length > 0 && column.Length <= 4000:
+			return getColumnTypeFor("varchar", target, column.Length)
+		case key:
+			// unbounded text cannot be a key everywhere
+			return getColumnTypeFor("varchar", target, 255)
+		default:
+			return getColumnTypeFor("text", target)
+		}
+	}
+}
+
+// Renders the CREATE TABLE statement of the table in the target dialect, with
+// the given foreign keys.
+func renderCreateTable(table TableSchema, foreignKeys []ForeignKey, source, target DatabaseType) (string, error) {
+	isKey := make(map[string]bool, len(table.PrimaryKeys))
+	for _, key := range table.PrimaryKeys {
+		isKey[key] = true
+	}
+
+	definitions := make([]string, 0, len(table.Columns)+1+len(foreignKeys))
+	for _, column := range table.Columns {
+		quotedColumn, err := quoteIdentifier(column.Name, target)
+		if err != nil {
+			return "", err
+		}
+		columnType, err := renderColumnType(column, isKey[column.Name], source, target)
+		if err != nil {
+			return "", err
+		}
+
+		definition := quotedColumn + " " + columnType
+		if !column.Nullable {
+			definition += " NOT NULL"
+		}
+		definitions = append(definitions, definition)
+	}
+
+	if len(table.PrimaryKeys) != 0 {
+		quotedKeys, err := quoteIdentifiers(table.PrimaryKeys, target)
+		if err != nil {
+			return "", err
+		}
+		definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(quotedKeys, ", ")))
+	}
+
+	for _, foreignKey := range foreignKeys {
+		quotedColumns, err := quoteIdentifiers(foreignKey.Columns, target)
+		if err != nil {
+			return "", err
+		}
+		quotedReferencedTable, err := quoteIdentifier(foreignKey.ReferencedTable, target)
+		if err != nil {
+			return "", err
+		}
+		quotedReferencedColumns, err := quoteIdentifiers(foreignKey.ReferencedColumns, target)
+		if err != nil {
+			return "", err
+		}
+		definitions = append(definitions, fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)",
+			strings.Join(quotedColumns, ", "),
+			quotedReferencedTable,
+			strings.Join(quotedReferencedColumns, ", "),
+		))
+	}
+
+	quotedTableName, err := quoteIdentifier(table.Name, target)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (%s)", quotedTableName, strings.Join(definitions, ", ")), nil
+}
+
+// Creates the table in the target dialect with the given foreign keys. It
+// returns false when WithIfNotExists finds the table existing.
+func createTable(db *sql.DB, o *options, table TableSchema, foreignKeys []ForeignKey, source, target DatabaseType) (bool, error) {
+	query, err := renderCreateTable(table, foreignKeys, source, target)
+	if err != nil {
+		return false, err
+	}
+
+	if o.ifNotExists && createTableIfNotExistsDatabases[target] {
+		// the check tells whether anything is created, IF NOT EXISTS covers
+		// a table created in between
+		exists, err := tableExists(db, o, table.Name, target)
+		if err != nil || exists {
+			return false, err
+		}
+		query = "CREATE TABLE IF NOT EXISTS" + strings.TrimPrefix(query, "CREATE TABLE")
+	}
+
+	if _, err := execStatement(db, o, query); err != nil {
+		err = classifyError(err)
+		if o.ifNotExists && errors.Is(err, ErrTableExists) {
+			return false, nil
+		}
+		return false, err
+	}
+	ResetColumnCache(db, table.Name)
+
+	return true, nil
+}
+
+// Orders the tables so that the tables referenced by foreign keys come before
+// the tables referencing them, keeping the given order otherwise. Tables in a
+// cycle keep their order.
+func sortTablesByDependencies(tables []TableSchema) []TableSchema {
+	index := make(map[string]int, len(tables))
+	for i, table := range tables {
+		index[table.Name] = i
+	}
+
+	sorted := make([]TableSchema, 0, len(tables))
+	state := make([]int, len(tables)) // 0 unvisited, 1 visiting, 2 done
+
+	var visit func(i int)
+	visit = func(i int) {
+		if state[i] != 0 {
+			return
+		}
+		state[i] = 1
+		for _, foreignKey := range tables[i].ForeignKeys {
+			if parent, ok := index[foreignKey.ReferencedTable]; ok && parent != i {
+				visit(parent)
+			}
+		}
+		state[i] = 2
+		sorted = append(sorted, tables[i])
+	}
+
+	for i := range tables {
+		visit(i)
+	}
+
+	return sorted
+}
@@ -0,0 +1,52 @@
+package sqlutils
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHook logs every statement to a slog.Logger, failed ones at error level.
+type SlogHook struct {
+	Logger *slog.Logger
+	// Level of the successful statements.
+	Level slog.Level
+	// LogArgs also logs the bound arguments, which may contain sensitive data.
+	LogArgs bool
+}
+
+// NewSlogHook returns a hook logging successful statements at debug level.
+func NewSlogHook(logger *slog.Logger) *SlogHook {
+	return &SlogHook{Logger: logger, Level: slog.LevelDebug}
+}
+
+func (h *SlogHook) BeforeQuery(ctx context.Context, _ *QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *SlogHook) AfterQuery(ctx context.Context, event *QueryEvent) {
+	logger := h.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	attrs := []slog.Attr{
+		slog.String("operation", string(event.Operation)),
+		slog.String("table", event.Table),
+		slog.String("sql", event.SQL),
+		slog.Duration("duration", event.Duration),
+	}
+	if h.LogArgs {
+		attrs = append(attrs, slog.Any("args", event.Args))
+	}
+	if event.RowsAffected >= 0 {
+		attrs = append(attrs, slog.Int64("rows_affected", event.RowsAffected))
+	}
+
+	if event.Err != nil {
+		attrs = append(attrs, slog.String("error", event.Err.Error()))
+		logger.LogAttrs(ctx, slog.LevelError, "sql statement failed", attrs...)
+		return
+	}
+
+	logger.LogAttrs(ctx, h.Level, "sql statement", attrs...)
+}
@@ -0,0 +1,458 @@
+package sqlutils
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// UnmappedColumnsError is returned when rows have columns that no field of
+// the struct maps to, unless WithLenientColumns is given.
+type UnmappedColumnsError struct {
+	Type    string
+	Columns []string
+}
+
+func (e *UnmappedColumnsError) Error() string {
+	return fmt.Sprintf("no field of %s for columns: %s", e.Type, strings.Join(e.Columns, ", "))
+}
+
+// A struct field mapped to a column. index leads to the field through the
+// embedded structs. The options come after the column name in the db tag:
+//
+//	pk        the field identifies the row in UpdateStruct and DeleteStruct
+//	omitempty zero values are not written
+//	readonly  the field is only read, never written
+//	auto      the database generates the value, InsertStruct reads it back
+type structField struct {
+	column    string
+	index     []int
+	pk        bool
+	omitEmpty bool
+	readOnly  bool
+	auto      bool
+}
+
+// Fields of the struct types seen so far.
+var structFieldCache sync.Map
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// Returns the columns of a struct type: the name given by the db tag, or the
+// field name in snake_case. Fields tagged db:"-" and unexported fields are
+// skipped, the fields of untagged embedded structs are promoted.
+func getStructFields(structType reflect.Type) ([]structField, error) {
+	if fields, ok := structFieldCache.Load(structType); ok {
+		return fields.([]structField), nil
+	}
+
+	if structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s is not a struct", structType)
+	}
+
+	var collected []structField
+	collectStructFields(structType, nil, &collected)
+
+	// like Go field promotion, the shallowest field wins
+	depths := make(map[string]int, len(collected))
+	for _, field := range collected {
+		depth, ok := depths[field.column]
+		if ok && depth == len(field.index) {
+			return nil, fmt.Errorf("%s maps several fields to column %s", structType, field.column)
+		}
+		if !ok || len(field.index) < depth {
+			depths[field.column] = len(field.index)
+		}
+	}
+
+	fields := make([]structField, 0, len(depths))
+	for _, field := range collected {
+		if depths[field.column] == len(field.index) {
+			fields = append(fields, field)
+		}
+	}
+
+	structFieldCache.Store(structType, fields)
+
+	return fields, nil
+}
+
+func collectStructFields(structType reflect.Type, parentIndex []int, fields *[]structField) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+
+		tag := field.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+		name, tagOptions, _ := strings.Cut(tag, ",")
+
+		index := append(append([]int(nil), parentIndex...), i)
+
+		if field.Anonymous && name == "" {
+			embeddedType := field.Type
+			if embeddedType.Kind() == reflect.Pointer {
+				embeddedType = embeddedType.Elem()
+			}
+			if embeddedType.Kind() == reflect.Struct && !isScalarStruct(embeddedType) {
+				collectStructFields(embeddedType, index, fields)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = toSnakeCase(field.Name)
+		}
+
+		mapped := structField{column: name, index: index}
+		for _, option := range strings.Split(tagOptions, ",") {
+			switch option {
+			case "pk":
+				mapped.pk = true
+			case "omitempty":
+				mapped.omitEmpty = true
+			case "readonly":
+				mapped.readOnly = true
+			case "auto":
+				mapped.auto = true
+			}
+		}
+
+		*fields = append(*fields, mapped)
+	}
+}
+
+// Structs holding a single value, such as time.Time or sql.NullString.
+func isScalarStruct(structType reflect.Type) bool {
+	return structType == timeType || reflect.PointerTo(structType).Implements(scannerType)
+}
+
+// Example: UserID -> user_id, HTTPServer -> http_server.
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// Finds the field of each column, exactly or else ignoring case as some
+// databases report upper case names. Columns without a field get -1.
+func mapColumnsToFields(columns []string, fields []structField) []int {
+	mapping := make([]int, len(columns))
+	for i, column := range columns {
+		mapping[i] = -1
+		for j, field := range fields {
+			if field.column == column {
+				mapping[i] = j
+				break
+			}
+		}
+		if mapping[i] != -1 {
+			continue
+		}
+		for j, field := range fields {
+			if strings.EqualFold(field.column, column) {
+				mapping[i] = j
+				break
+			}
+		}
+	}
+	return mapping
+}
+
+// Returns the field at the index, allocating the nil embedded pointers on the
+// way.
+func fieldByIndexAlloc(value reflect.Value, index []int) reflect.Value {
+	for depth, i := range index {
+		if depth > 0 && value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		value = value.Field(i)
+	}
+	return value
+}
+
+// ScanAll reads the rows into structs of type T, matching the columns with
+// the db tags or the snake_case names of the fields. NULL values need pointer
+// or sql.Scanner fields. Columns without a field are an UnmappedColumnsError,
+// WithLenientColumns skips them instead. The rows are closed.
+func ScanAll[T any](rows *sql.Rows, opts ...Option) ([]T, error) {
+	defer rows.Close()
+
+	o := newOptions(nil, "", "", opts)
+
+	structType := reflect.TypeOf((*T)(nil)).Elem()
+	fields, err := getStructFields(structType)
+	if err != nil {
+		return nil, fmt.Errorf("ScanAll - %w", err)
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("ScanAll - retrieving columns: %w", err)
+	}
+
+	mapping := mapColumnsToFields(columns, fields)
+
+	var unmapped []string
+	for i, fieldIndex := range mapping {
+		if fieldIndex == -1 {
+			unmapped = append(unmapped, columns[i])
+		}
+	}
+	if len(unmapped) != 0 && !o.lenientColumns {
+		sort.Strings(unmapped)
+		return nil, fmt.Errorf("ScanAll - %w", &UnmappedColumnsError{Type: structType.String(), Columns: unmapped})
+	}
+
+	results := []T{}
+	for rows.Next() {
+		var result T
+		value := reflect.ValueOf(&result).Elem()
+
+		dest := make([]interface{}, len(columns))
+		for i, fieldIndex := range mapping {
+			if fieldIndex == -1 {
+				dest[i] = new(interface{})
+				continue
+			}
+			dest[i] = fieldByIndexAlloc(value, fields[fieldIndex].index).Addr().Interface()
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("ScanAll - scanning row: %w", err)
+		}
+
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ScanAll - rows iteration: %w", err)
+	}
+
+	return results, nil
+}
+
+// GetTableAs returns the records of the table as structs of type T, see
+// ScanAll for the mapping.
+func GetTableAs[T any](db *sql.DB, tableName string, dbType DatabaseType, opts ...Option) ([]T, error) {
+	o := newOptions(db, OpGetTable, tableName, opts)
+
+	if err := checkGuard(o, tableName); err != nil {
+		return nil, fmt.Errorf("GetTableAs - %w", err)
+	}
+
+	query, err := getQueryForAllRecords(tableName, dbType)
+	if err != nil {
+		return nil, fmt.Errorf("GetTableAs - grabbing db type specific query: %w", err)
+	}
+	query, args, err := applyFilters(query, o, dbType)
+	if err != nil {
+		return nil, fmt.Errorf("GetTableAs - %w", err)
+	}
+
+	rows, err := queryRows(db, o, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetTableAs - query: %w", classifyError(err))
+	}
+	defer rows.Close()
+
+	results, err := ScanAll[T](rows.Rows, inherit(o))
+	if err != nil {
+		return nil, fmt.Errorf("GetTableAs - %w", err)
+	}
+
+	return results, nil
+}
+
+// Returns the field at the index, ok is false when a nil embedded pointer is
+// on the way.
+func fieldByIndexRead(value reflect.Value, index []int) (reflect.Value, bool) {
+	for depth, i := range index {
+		if depth > 0 && value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				return reflect.Value{}, false
+			}
+			value = value.Elem()
+		}
+		value = value.Field(i)
+	}
+	return value, true
+}
+
+// Dereferences pointers to the struct, nil pointers are an error.
+func structValue(value interface{}) (reflect.Value, []structField, error) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Value{}, nil, fmt.Errorf("nil %s", v.Type())
+		}
+		v = v.Elem()
+	}
+
+	fields, err := getStructFields(v.Type())
+	if err != nil {
+		return reflect.Value{}, nil, err
+	}
+
+	return v, fields, nil
+}
+
+// Builds the record of the fields accepted by include, leaving out the
+// readonly fields and the empty omitempty ones.
+func structRecord(value reflect.Value, fields []structField, include func(structField) bool) TableRecord {
+	record := TableRecord{}
+	for _, field := range fields {
+		if field.readOnly || !include(field) {
+			continue
+		}
+
+		fieldValue, ok := fieldByIndexRead(value, field.index)
+		if !ok || (field.omitEmpty && fieldValue.IsZero()) {
+			continue
+		}
+
+		record[field.column] = fieldValue.Interface()
+	}
+	return record
+}
+
+// InsertStruct inserts the struct like InsertRecord. The auto fields are left
+// to the database and, for a single integer auto field left empty, set to the
+// generated id.
+func InsertStruct[T any](db *sql.DB, tableName string, value *T, databaseType DatabaseType, opts ...Option) (int64, error) {
+	v, fields, err := structValue(value)
+	if err != nil {
+		return 0, fmt.Errorf("InsertStruct - %w", err)
+	}
+
+	record := structRecord(v, fields, func(field structField) bool {
+		return !field.auto
+	})
+
+	id, err := InsertRecord(db, tableName, record, databaseType, opts...)
+	if err != nil {
+		return 0, fmt.Errorf("InsertStruct - %w", err)
+	}
+
+	var autoFields []structField
+	for _, field := range fields {
+		if field.auto {
+			autoFields = append(autoFields, field)
+		}
+	}
+	if len(autoFields) == 1 && id != 0 {
+		fieldValue := fieldByIndexAlloc(v, autoFields[0].index)
+		if fieldValue.IsZero() {
+			switch fieldValue.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				fieldValue.SetInt(id)
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				fieldValue.SetUint(uint64(id))
+			}
+		}
+	}
+
+	return id, nil
+}
+
+// UpdateStruct sets the columns of the row identified by the pk fields to the
+// values of the other fields, in a single statement. The auto fields are not
+// written.
+func UpdateStruct[T any](db *sql.DB, tableName string, value T, databaseType DatabaseType, opts ...Option) error {
+	o := newOptions(db, OpEditRecord, tableName, opts)
+
+	v, fields, err := structValue(value)
+	if err != nil {
+		return fmt.Errorf("UpdateStruct - %w", err)
+	}
+
+	identity := TableRecord{}
+	for _, field := range fields {
+		if !field.pk {
+			continue
+		}
+		fieldValue, ok := fieldByIndexRead(v, field.index)
+		if !ok {
+			return fmt.Errorf("UpdateStruct - %w: %s", ErrPrimaryKeyMissing, field.column)
+		}
+		identity[field.column] = fieldValue.Interface()
+	}
+	if len(identity) == 0 {
+		return fmt.Errorf("UpdateStruct - %w: no pk field in %s", ErrPrimaryKeyMissing, v.Type())
+	}
+
+	updates := structRecord(v, fields, func(field structField) bool {
+		return !field.pk && !field.auto
+	})
+
+	if err := updateRecord(db, o, tableName, identity, updates, databaseType); err != nil {
+		return fmt.Errorf("UpdateStruct - %w", err)
+	}
+
+	return nil
+}
+
+// DeleteStruct removes the row like RemoveRecord, identified by the pk fields
+// or, without any, by all the fields.
+func DeleteStruct[T any](db *sql.DB, tableName string, value T, databaseType DatabaseType, opts ...Option) (int64, error) {
+	v, fields, err := structValue(value)
+	if err != nil {
+		return 0, fmt.Errorf("DeleteStruct - %w", err)
+	}
+
+	hasPrimaryKey := false
+	for _, field := range fields {
+		hasPrimaryKey = hasPrimaryKey || field.pk
+	}
+
+	record := TableRecord{}
+	for _, field := range fields {
+		if hasPrimaryKey && !field.pk {
+			continue
+		}
+		fieldValue, ok := fieldByIndexRead(v, field.index)
+		if !ok {
+			continue
+		}
+		// NULL never compares equal, so nil fields cannot identify the row
+		if !hasPrimaryKey && fieldValue.Kind() == reflect.Pointer && fieldValue.IsNil() {
+			continue
+		}
+		record[field.column] = fieldValue.Interface()
+	}
+
+	rowsAffected, err := RemoveRecord(db, "", tableName, databaseType, record, opts...)
+	if err != nil {
+		return 0, fmt.Errorf("DeleteStruct - %w", err)
+	}
+
+	return rowsAffected, nil
+}
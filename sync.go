@@ -0,0 +1,331 @@
+package sqlutils
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// SyncAction is the change SyncTable makes to a target row.
+type SyncAction string
+
+const (
+	SyncInsert SyncAction = "insert"
+	SyncUpdate SyncAction = "update"
+	SyncDelete SyncAction = "delete"
+)
+
+// WithDeletes lets SyncTable delete the target rows missing from the source,
+// at most max of them, any number when max is negative. Without it these rows
+// are kept. With a limit both tables are first walked without changing
+// anything, SyncTable failing with ErrDeleteLimit before the first change when
+// there are more rows to delete.
+func WithDeletes(max int) Option {
+	return func(o *options) {
+		o.syncDeletes = true
+		o.maxDeletes = max
+	}
+}
+
+// SyncConflict is a change of SyncTable the target rejected, such as a row
+// breaking a unique or foreign key constraint.
+type SyncConflict struct {
+	Action SyncAction  `json:"action"`
+	Key    TableRecord `json:"key"`
+	Reason string      `json:"reason"`
+}
+
+// SyncReport is the outcome of SyncTable. With WithDryRun the counts are the
+// changes planned.
+type SyncReport struct {
+	Inserted  int64 `json:"inserted"`
+	Updated   int64 `json:"updated"`
+	Deleted   int64 `json:"deleted"`
+	Unchanged int64 `json:"unchanged"`
+	// Kept counts the target rows missing from the source left in place
+	// without WithDeletes.
+	Kept      int64          `json:"kept"`
+	Conflicts []SyncConflict `json:"conflicts"`
+}
+
+// SyncTable makes the rows of the table in dst match those of the table in
+// src, the two databases being of the same dialect or not. Rows are matched
+// by the primary key of the source table and only the changed columns of a
+// row are updated. Both tables are walked in primary key order, the changes
+// of each range of WithChunkSize rows being applied in one transaction; when
+// it fails the changes are retried one by one and those the target rejects
+// are reported as conflicts.
+//
+// Target rows missing from the source are only deleted with WithDeletes.
+// WithColumns limits the columns synced and WithFilters the rows, on both
+// sides. WithMasking masks the source values, not the primary key. WithDryRun
+// reads both tables and plans the changes without applying them.
+func SyncTable(
+	src *sql.DB,
+	dst *sql.DB,
+	tableName string,
+	srcType DatabaseType,
+	dstType DatabaseType,
+	opts ...Option,
+) (SyncReport, error) {
+	var report SyncReport
+
+	// the source is only read
+	srcOptions := newOptions(src, OpGetTable, tableName, opts)
+	o := newOptions(dst, OpSyncTable, tableName, opts)
+
+	if err := checkGuard(srcOptions, tableName); err != nil {
+		return report, fmt.Errorf("SyncTable - %w", err)
+	}
+	if err := checkGuard(o, tableName); err != nil {
+		return report, fmt.Errorf("SyncTable - %w", err)
+	}
+
+	source, target, err := openTablePair(src, srcOptions, tableName, srcType, dst, o, tableName, dstType)
+	if err != nil {
+		return report, fmt.Errorf("SyncTable - %w", err)
+	}
+
+	// masked keys would no longer match the target rows
+	if srcOptions.masking != nil {
+		for _, key := range source.primaryKeys {
+			if srcOptions.masking.rule(tableName, key) != nil {
+				return report, fmt.Errorf("SyncTable - primary key %s cannot be masked", key)
+			}
+		}
+	}
+
+	if o.syncDeletes && o.maxDeletes >= 0 {
+		if err := checkDeleteLimit(source, target, o.maxDeletes); err != nil {
+			return report, fmt.Errorf("SyncTable - %w", err)
+		}
+
+		// the walk used up the readers
+		source, target, err = openTablePair(src, srcOptions, tableName, srcType, dst, o, tableName, dstType)
+		if err != nil {
+			return report, fmt.Errorf("SyncTable - %w", err)
+		}
+	}
+
+	schemas, err := getMaskedColumnSchemas(dst, o, tableName, dstType)
+	if err != nil {
+		return report, fmt.Errorf("SyncTable - %w", err)
+	}
+
+	syncer := &tableSyncer{
+		db:        dst,
+		o:         o,
+		tableName: tableName,
+		dbType:    dstType,
+		chunkSize: source.chunkSize,
+		report:    &report,
+	}
+
+	var deletes int64
+	err = mergeTables(source, target, func(srcRow, dstRow []interface{}) error {
+		if srcRow != nil {
+			var err error
+			if srcRow, err = maskRow(srcOptions, tableName, source.columns, schemas, srcRow); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case dstRow == nil:
+			return syncer.add(SyncInsert, source.keyRecord(srcRow), source.record(srcRow))
+		case srcRow == nil:
+			if !o.syncDeletes {
+				report.Kept++
+				return nil
+			}
+			// rows removed from the source since the first walk, the pending
+			// changes are dropped but the chunks applied already stay
+			deletes++
+			if o.maxDeletes >= 0 && deletes > int64(o.maxDeletes) {
+				return fmt.Errorf("%w: more than %d target rows to delete", ErrDeleteLimit, o.maxDeletes)
+			}
+			return syncer.add(SyncDelete, target.keyRecord(dstRow), nil)
+		default:
+			changes := diffRow(source.columns, srcRow, dstRow)
+			if len(changes) == 0 {
+				report.Unchanged++
+				return nil
+			}
+			updates := make(TableRecord, len(changes))
+			for _, change := range changes {
+				updates[change.Column] = change.A
+			}
+			return syncer.add(SyncUpdate, source.keyRecord(srcRow), updates)
+		}
+	})
+	if err == nil {
+		err = syncer.flush()
+	}
+	if err != nil {
+		return report, fmt.Errorf("SyncTable - %w", err)
+	}
+
+	err = writeAudit(o, AuditEntry{Details: map[string]interface{}{
+		"inserted":  report.Inserted,
+		"updated":   report.Updated,
+		"deleted":   report.Deleted,
+		"conflicts": len(report.Conflicts),
+	}})
+	if err != nil {
+		return report, fmt.Errorf("SyncTable - %w", err)
+	}
+
+	return report, nil
+}
+
+// Walks both tables without changing anything, failing with ErrDeleteLimit
+// when more than max target rows are missing from the source.
+func checkDeleteLimit(source, target *keysetReader, max int) error {
+	var deletes int64
+	return mergeTables(source, target, func(srcRow, dstRow []interface{}) error {
+		if srcRow != nil {
+			return nil
+		}
+		deletes++
+		if deletes > int64(max) {
+			return fmt.Errorf("%w: more than %d target rows to delete", ErrDeleteLimit, max)
+		}
+		return nil
+	})
+}
+
+// A change waiting for its chunk to be applied.
+type syncChange struct {
+	action SyncAction
+	key    TableRecord
+	query  string
+	args   []interface{}
+}
+
+type tableSyncer struct {
+	db        *sql.DB
+	o         *options
+	tableName string
+	dbType    DatabaseType
+	chunkSize int
+	pending   []syncChange
+	report    *SyncReport
+}
+
+// Queues the change, the values being the row to insert or the columns to
+// update, and applies the chunk once full.
+func (s *tableSyncer) add(action SyncAction, key TableRecord, values TableRecord) error {
+	quotedTableName, err := quoteIdentifier(s.tableName, s.dbType)
+	if err != nil {
+		return err
+	}
+
+	keyColumns, keyValues := extractRecordData(key)
+	change := syncChange{action: action, key: key}
+
+	switch action {
+	case SyncInsert:
+		columns, args := extractRecordData(values)
+		quotedColumns, err := quoteIdentifiers(columns, s.dbType)
+		if err != nil {
+			return err
+		}
+		placeholders, err := getPlaceholders(s.dbType, 1, len(args))
+		if err != nil {
+			return err
+		}
+		change.query = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+			quotedTableName,
+			strings.Join(quotedColumns, ", "),
+			strings.Join(placeholders, ", "),
+		)
+		change.args = args
+	case SyncUpdate:
+		columns, args := extractRecordData(values)
+		assignments, err := computeAssignments(columns, s.dbType, 1)
+		if err != nil {
+			return err
+		}
+		conditions, err := computeConditions(keyColumns, s.dbType, len(args)+1)
+		if err != nil {
+			return err
+		}
+		change.query = fmt.Sprintf("UPDATE %s SET %s WHERE %s", quotedTableName, assignments, conditions)
+		change.args = append(args, keyValues...)
+	case SyncDelete:
+		conditions, err := computeConditions(keyColumns, s.dbType, 1)
+		if err != nil {
+			return err
+		}
+		change.query = fmt.Sprintf("DELETE FROM %s WHERE %s", quotedTableName, conditions)
+		change.args = keyValues
+	}
+
+	if change.args, err = encodeValues(s.o, change.args); err != nil {
+		return err
+	}
+
+	s.pending = append(s.pending, change)
+	if len(s.pending) >= s.chunkSize {
+		return s.flush()
+	}
+	return nil
+}
+
+// Applies the pending changes in one transaction, or one at a time when the
+// transaction fails.
+func (s *tableSyncer) flush() error {
+	batch := s.pending
+	s.pending = nil
+	if len(batch) == 0 {
+		return nil
+	}
+
+	err := inTransaction(s.db, s.o, true, func(e execer) error {
+		for _, change := range batch {
+			if _, err := execStatement(e, s.o, change.query, change.args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		for _, change := range batch {
+			s.count(change.action)
+		}
+		return nil
+	}
+
+	for _, change := range batch {
+		if err := s.o.ctx.Err(); err != nil {
+			return err
+		}
+
+		err := inTransaction(s.db, s.o, true, func(e execer) error {
+			_, err := execStatement(e, s.o, change.query, change.args...)
+			return err
+		})
+		if err != nil {
+			s.report.Conflicts = append(s.report.Conflicts, SyncConflict{
+				Action: change.action,
+				Key:    change.key,
+				Reason: classifyError(err).Error(),
+			})
+			continue
+		}
+		s.count(change.action)
+	}
+
+	return nil
+}
+
+func (s *tableSyncer) count(action SyncAction) {
+	switch action {
+	case SyncInsert:
+		s.report.Inserted++
+	case SyncUpdate:
+		s.report.Updated++
+	case SyncDelete:
+		s.report.Deleted++
+	}
+}
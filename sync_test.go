@@ -0,0 +1,135 @@
+package sqlutils
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func openSyncTestDBs(t *testing.T) (*sql.DB, *sql.DB) {
+	t.Helper()
+
+	src := openTestDB(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)",
+		"INSERT INTO users VALUES (1, 'ann'), (2, 'bob'), (4, 'dan')",
+	)
+	dst := openTestDB(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)",
+		"INSERT INTO users VALUES (1, 'ann'), (2, 'robert'), (3, 'cid'), (5, 'eve'), (6, 'fay')",
+	)
+	return src, dst
+}
+
+func userNames(t *testing.T, db *sql.DB) map[int64]string {
+	t.Helper()
+
+	rows, err := db.Query("SELECT id, name FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	names := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			t.Fatal(err)
+		}
+		names[id] = name
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestSyncTable(t *testing.T) {
+	src, dst := openSyncTestDBs(t)
+
+	report, err := SyncTable(src, dst, "users", SQLite, SQLite, WithDeletes(-1), WithChunkSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Inserted != 1 || report.Updated != 1 || report.Deleted != 3 || report.Unchanged != 1 {
+		t.Fatalf("SyncTable reported %+v, want 1 insert, 1 update, 3 deletes and 1 unchanged row", report)
+	}
+
+	names := userNames(t, dst)
+	want := userNames(t, src)
+	if len(names) != len(want) {
+		t.Fatalf("target rows are %v, want %v", names, want)
+	}
+	for id, name := range want {
+		if names[id] != name {
+			t.Fatalf("target rows are %v, want %v", names, want)
+		}
+	}
+}
+
+func TestSyncTableKeepsRowsWithoutDeletes(t *testing.T) {
+	src, dst := openSyncTestDBs(t)
+
+	report, err := SyncTable(src, dst, "users", SQLite, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Deleted != 0 || report.Kept != 3 {
+		t.Fatalf("SyncTable reported %+v, want 3 kept rows", report)
+	}
+	if got := countRows(t, dst, "users"); got != 6 {
+		t.Fatalf("target has %d rows, want 6", got)
+	}
+}
+
+func TestSyncTableDeleteLimit(t *testing.T) {
+	src, dst := openSyncTestDBs(t)
+	before := userNames(t, dst)
+
+	// the rows to delete come after the insert and the update
+	_, err := SyncTable(src, dst, "users", SQLite, SQLite, WithDeletes(2), WithChunkSize(1))
+	if !errors.Is(err, ErrDeleteLimit) {
+		t.Fatalf("SyncTable returned %v, want ErrDeleteLimit", err)
+	}
+
+	after := userNames(t, dst)
+	if len(after) != len(before) {
+		t.Fatalf("target rows changed from %v to %v", before, after)
+	}
+	for id, name := range before {
+		if after[id] != name {
+			t.Fatalf("target rows changed from %v to %v", before, after)
+		}
+	}
+
+	if _, err := SyncTable(src, dst, "users", SQLite, SQLite, WithDeletes(3)); err != nil {
+		t.Fatalf("SyncTable within the limit returned %v", err)
+	}
+}
+
+func TestSyncTableDryRun(t *testing.T) {
+	src, dst := openSyncTestDBs(t)
+	before := userNames(t, dst)
+
+	plan := &Plan{}
+	report, err := SyncTable(src, dst, "users", SQLite, SQLite, WithDeletes(-1), WithDryRun(plan))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Inserted != 1 || report.Updated != 1 || report.Deleted != 3 {
+		t.Fatalf("SyncTable planned %+v, want 1 insert, 1 update and 3 deletes", report)
+	}
+	if len(plan.Statements) == 0 {
+		t.Fatal("the plan holds no statements")
+	}
+
+	after := userNames(t, dst)
+	for id, name := range before {
+		if after[id] != name {
+			t.Fatalf("dry run changed the target rows from %v to %v", before, after)
+		}
+	}
+	if len(after) != len(before) {
+		t.Fatalf("dry run changed the target rows from %v to %v", before, after)
+	}
+}
@@ -2,36 +2,59 @@ package sqlutils
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"time"
+	"io"
 )
 
-func doesTableExist(db *sql.DB, tableName string, dbType DatabaseType) error {
-	quoteChar, ok := quoteCharMap[dbType]
-	if !ok {
-		return fmt.Errorf("%s - database type not supported", getCurrentFuncName())
+func doesTableExist(db *sql.DB, o *options, tableName string, dbType DatabaseType) error {
+	exists, err := tableExists(db, o, tableName, dbType)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrTableNotFound, tableName)
 	}
 
-	query := fmt.Sprintf("SELECT 1 FROM %s%s%s", quoteChar, tableName, quoteChar)
+	return nil
+}
 
-	rows, err := db.Query(query)
+// Looks the table up in the catalog, so a missing table is not confused with
+// any other query failure.
+func tableExists(db *sql.DB, o *options, tableName string, dbType DatabaseType) (bool, error) {
+	query, err := getQueryForTableExists(dbType)
 	if err != nil {
-		return fmt.Errorf("IsTableExistent - query: %w", err)
+		return false, fmt.Errorf("tableExists - grabbing db type specific query: %w", err)
 	}
-	rows.Close()
 
-	return nil
+	var count int
+	if err := queryRow(db, o, []interface{}{&count}, query, tableName); err != nil {
+		return false, fmt.Errorf("tableExists - query: %w", classifyError(err))
+	}
+
+	return count > 0, nil
 }
 
-func GetTables(db *sql.DB, dbName string, dbType DatabaseType) ([]string, error) {
-	query, err := getQueryForTables(dbName, dbType)
+func GetTables(db *sql.DB, dbName string, dbType DatabaseType, opts ...Option) ([]string, error) {
+	o := newOptions(db, OpGetTables, "", opts)
+
+	if err := checkGuard(o); err != nil {
+		return nil, fmt.Errorf("GetTables - %w", err)
+	}
+
+	query, err := getQueryForTables(dbType)
 	if err != nil {
 		return nil, fmt.Errorf("GetTables - grabbing db type specific query: %w", err)
 	}
 
-	rows, err := db.Query(query)
+	var args []interface{}
+	if dbType != SQLite {
+		args = append(args, dbName)
+	}
+
+	rows, err := queryRows(db, o, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("GetTables - fetching tables: %w", err)
+		return nil, fmt.Errorf("GetTables - fetching tables: %w", classifyError(err))
 	}
 	defer rows.Close()
 
@@ -43,18 +66,47 @@ func GetTables(db *sql.DB, dbName string, dbType DatabaseType) ([]string, error)
 		}
 		tableNames = append(tableNames, tableName)
 	}
-	return tableNames, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetTables - rows iteration: %w", err)
+	}
+
+	return filterAllowedTables(o, tableNames), nil
 }
 
-func GetTable(db *sql.DB, tableName string, dbType DatabaseType) ([]map[string]interface{}, error) {
+func GetTable(db *sql.DB, tableName string, dbType DatabaseType, opts ...Option) ([]map[string]interface{}, error) {
+	o := newOptions(db, OpGetTable, tableName, opts)
+
+	if err := checkGuard(o, tableName); err != nil {
+		return nil, fmt.Errorf("GetTable - %w", err)
+	}
+
 	query, err := getQueryForAllRecords(tableName, dbType)
 	if err != nil {
 		return nil, fmt.Errorf("GetTable - grabbing db type specific query: %w", err)
 	}
+	query, args, err := applyFilters(query, o, dbType)
+	if err != nil {
+		return nil, fmt.Errorf("GetTable - %w", err)
+	}
+
+	codec := getValueCodec(o)
+
+	var jsonColumns map[string]bool
+	if codec.ParseJSON {
+		jsonColumns, err = getJSONColumns(db, o, tableName, dbType)
+		if err != nil {
+			return nil, fmt.Errorf("GetTable - grabbing JSON columns: %w", err)
+		}
+	}
+
+	boolColumns, err := getBoolColumns(db, o, tableName, dbType)
+	if err != nil {
+		return nil, fmt.Errorf("GetTable - grabbing boolean columns: %w", err)
+	}
 
-	rows, err := db.Query(query)
+	rows, err := queryRows(db, o, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("GetTable - query: %w", err)
+		return nil, fmt.Errorf("GetTable - query: %w", classifyError(err))
 	}
 	defer rows.Close()
 
@@ -63,6 +115,20 @@ func GetTable(db *sql.DB, tableName string, dbType DatabaseType) ([]map[string]i
 		return nil, fmt.Errorf("GetTable - retrieving columns: %w", err)
 	}
 
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("GetTable - retrieving column types: %w", err)
+	}
+	decodings := getColumnDecodings(columnTypes)
+	for i, column := range columns {
+		if jsonColumns[column] {
+			decodings[i].kind = kindJSON
+		}
+		if boolColumns[column] {
+			decodings[i].kind = kindBool
+		}
+	}
+
 	results := []map[string]interface{}{}
 
 	for rows.Next() {
@@ -79,22 +145,9 @@ func GetTable(db *sql.DB, tableName string, dbType DatabaseType) ([]map[string]i
 
 		result := make(map[string]interface{})
 		for i, col := range columns {
-			switch v := values[i].(type) {
-			case []byte:
-				if isBase64(v) {
-					decoded, err := decodeBase64(v)
-					if err == nil {
-						result[col] = decoded
-					} else {
-						result[col] = string(v)
-					}
-				} else {
-					result[col] = string(v)
-				}
-			case time.Time:
-				result[col] = v.Format(time.RFC3339)
-			default:
-				result[col] = v
+			result[col], err = decodeColumnValue(values[i], decodings[i], codec, o)
+			if err != nil {
+				return nil, fmt.Errorf("GetTable - column %s: %w", col, err)
 			}
 		}
 
@@ -108,8 +161,14 @@ func GetTable(db *sql.DB, tableName string, dbType DatabaseType) ([]map[string]i
 	return results, nil
 }
 
-func GetColumns(db *sql.DB, tableName string, databaseType DatabaseType) ([]string, error) {
-	err := doesTableExist(db, tableName, databaseType)
+func GetColumns(db *sql.DB, tableName string, databaseType DatabaseType, opts ...Option) ([]string, error) {
+	o := newOptions(db, OpGetColumns, tableName, opts)
+
+	if err := checkGuard(o, tableName); err != nil {
+		return nil, fmt.Errorf("GetColumns - %w", err)
+	}
+
+	err := doesTableExist(db, o, tableName, databaseType)
 	if err != nil {
 		return nil, fmt.Errorf("GetColumns - %w", err)
 	}
@@ -119,43 +178,38 @@ func GetColumns(db *sql.DB, tableName string, databaseType DatabaseType) ([]stri
 		return nil, fmt.Errorf("GetColumns - grabbing db type specific query: %w", err)
 	}
 
-	rows, err := db.Query(query, tableName)
+	rows, err := queryRows(db, o, query, tableName)
 	if err != nil {
-		return nil, fmt.Errorf("GetColumns: %v", err)
+		return nil, fmt.Errorf("GetColumns: %w", classifyError(err))
 	}
 	defer rows.Close()
 
 	var columns []string
 	for rows.Next() {
 		var column string
-		if databaseType == SQLite {
-			var columnInfo struct {
-				Name string
-			}
-			if err := rows.Scan(&columnInfo.Name); err != nil {
-				return nil, fmt.Errorf("GetColumns: %v", err)
-			}
-			columns = append(columns, columnInfo.Name)
-		} else {
-			if err := rows.Scan(&column); err != nil {
-				return nil, fmt.Errorf("GetColumns: %v", err)
-			}
-			columns = append(columns, column)
+		if err := rows.Scan(&column); err != nil {
+			return nil, fmt.Errorf("GetColumns: %w", err)
 		}
+		columns = append(columns, column)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("GetColumns: %v", err)
+		return nil, fmt.Errorf("GetColumns: %w", err)
 	}
 
 	return columns, nil
 }
 
-func GetPrimaryKeys(db *sql.DB, dbName, tableName string, databaseType DatabaseType) ([]string, error) {
-	var err error
-	err = doesTableExist(db, tableName, databaseType)
+func GetPrimaryKeys(db *sql.DB, dbName, tableName string, databaseType DatabaseType, opts ...Option) ([]string, error) {
+	o := newOptions(db, OpGetPrimaryKeys, tableName, opts)
+
+	if err := checkGuard(o, tableName); err != nil {
+		return nil, fmt.Errorf("GetPrimaryKeys - %w", err)
+	}
+
+	err := doesTableExist(db, o, tableName, databaseType)
 	if err != nil {
-		return nil, fmt.Errorf("GetColumns - %w", err)
+		return nil, fmt.Errorf("GetPrimaryKeys - %w", err)
 	}
 
 	query, err := getQueryForPrimaryKeys(databaseType)
@@ -163,41 +217,36 @@ func GetPrimaryKeys(db *sql.DB, dbName, tableName string, databaseType DatabaseT
 		return nil, fmt.Errorf("GetPrimaryKeys - grabbing db type specific query: %w", err)
 	}
 
-	var rows *sql.Rows
+	var rows *hookedRows
 
 	switch databaseType {
-	case SQLite, PostgreSQL:
-		rows, err = db.Query(query, tableName)
+	case PostgreSQL:
+		// regclass parses the name like SQL does, so it has to be quoted
+		var quotedTableName string
+		quotedTableName, err = quoteIdentifier(tableName, databaseType)
+		if err != nil {
+			return nil, fmt.Errorf("GetPrimaryKeys - %w", err)
+		}
+		rows, err = queryRows(db, o, query, quotedTableName)
+	case SQLite, Oracle:
+		// the Oracle owner is the connected user
+		rows, err = queryRows(db, o, query, tableName)
 	default:
-		rows, err = db.Query(query, dbName, tableName)
+		rows, err = queryRows(db, o, query, dbName, tableName)
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("GetPrimaryKeys: failed to execute query: %w", err)
+		return nil, fmt.Errorf("GetPrimaryKeys: failed to execute query: %w", classifyError(err))
 	}
 	defer rows.Close()
 
 	var primaryKeys []string
 	for rows.Next() {
 		var columnName string
-		if databaseType == SQLite {
-			var columnInfo struct {
-				Name       string
-				NotNull    int
-				PrimaryKey int
-			}
-			if err := rows.Scan(&columnInfo.Name, &columnInfo.NotNull, &columnInfo.PrimaryKey); err != nil {
-				return nil, fmt.Errorf("GetPrimaryKeys: failed to scan row: %w", err)
-			}
-			if columnInfo.PrimaryKey == 1 {
-				primaryKeys = append(primaryKeys, columnInfo.Name)
-			}
-		} else {
-			if err := rows.Scan(&columnName); err != nil {
-				return nil, fmt.Errorf("GetPrimaryKeys: failed to scan row: %w", err)
-			}
-			primaryKeys = append(primaryKeys, columnName)
+		if err := rows.Scan(&columnName); err != nil {
+			return nil, fmt.Errorf("GetPrimaryKeys: failed to scan row: %w", err)
 		}
+		primaryKeys = append(primaryKeys, columnName)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -207,10 +256,10 @@ func GetPrimaryKeys(db *sql.DB, dbName, tableName string, databaseType DatabaseT
 	return primaryKeys, nil
 }
 
-func getColumnTypes(db *sql.DB, dbName string, tableName string, databaseType DatabaseType) (map[string]string, error) {
-	placeholder, ok := placeholderMap[databaseType]
-	if !ok {
-		return nil, fmt.Errorf("%s - database type not supported", getCurrentFuncName())
+func getColumnTypes(db *sql.DB, o *options, dbName string, tableName string, databaseType DatabaseType) (map[string]string, error) {
+	placeholders, err := getPlaceholders(databaseType, 1, 2)
+	if err != nil {
+		return nil, fmt.Errorf("%s - %w", getCurrentFuncName(), err)
 	}
 
 	// TODO: add support for other dbs
@@ -218,11 +267,11 @@ func getColumnTypes(db *sql.DB, dbName string, tableName string, databaseType Da
 		SELECT column_name, column_type
 		FROM information_schema.columns
 		WHERE table_schema = %s AND table_name = %s;
-	`, placeholder, placeholder)
+	`, placeholders[0], placeholders[1])
 
-	rows, err := db.Query(query, dbName, tableName)
+	rows, err := queryRows(db, o, query, dbName, tableName)
 	if err != nil {
-		return nil, fmt.Errorf("%s - %v", getCurrentFuncName(), err)
+		return nil, fmt.Errorf("%s - %w", getCurrentFuncName(), classifyError(err))
 	}
 	defer rows.Close()
 
@@ -231,87 +280,464 @@ func getColumnTypes(db *sql.DB, dbName string, tableName string, databaseType Da
 	for rows.Next() {
 		var columnName, columnType string
 		if err := rows.Scan(&columnName, &columnType); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %v", err)
+			return nil, fmt.Errorf("%s - failed to scan row: %w", getCurrentFuncName(), err)
 		}
 		columnTypes[columnName] = columnType
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("GetPrimaryKeys: rows iteration error: %w", err)
+		return nil, fmt.Errorf("%s - rows iteration error: %w", getCurrentFuncName(), err)
 	}
 
 	return columnTypes, nil
 }
 
-func DuplicateTable(db *sql.DB, originalTableName, newTableName string, databaseType DatabaseType) error {
-	if newTableName != "" && !isValidTableName(newTableName) {
-		return fmt.Errorf("DuplicateTable: table names must contain only letters, numbers, underscores, and dashes")
+// CreateTable creates the table described by the schema, see DescribeTable,
+// with its primary key and foreign keys. WithIfNotExists skips an already
+// existing table.
+func CreateTable(db *sql.DB, table TableSchema, databaseType DatabaseType, opts ...Option) (DDLResult, error) {
+	o := newOptions(db, OpCreateTable, table.Name, opts)
+
+	if err := checkGuard(o, table.Name); err != nil {
+		return DDLResult{}, fmt.Errorf("CreateTable - %w", err)
 	}
 
+	changed, err := createTable(db, o, table, table.ForeignKeys, databaseType, databaseType)
+	if err != nil {
+		return DDLResult{}, fmt.Errorf("CreateTable: failed to create table %s: %w", table.Name, err)
+	}
+	if !changed {
+		return DDLResult{}, nil
+	}
+
+	if err := writeAudit(o, AuditEntry{}); err != nil {
+		return DDLResult{Changed: true}, fmt.Errorf("CreateTable - %w", err)
+	}
+
+	return DDLResult{Changed: true}, nil
+}
+
+// DuplicateTable copies the structure and the data of a table, into a table
+// named after it when newTableName is empty. WithIfExists skips a missing
+// original table and WithIfNotExists an already existing copy.
+// With WithMasking the rows are read, masked and loaded into the copy instead
+// of being copied by the database. A copy whose data fails to load is dropped.
+func DuplicateTable(db *sql.DB, originalTableName, newTableName string, databaseType DatabaseType, opts ...Option) (DDLResult, error) {
+	o := newOptions(db, OpDuplicateTable, originalTableName, opts)
+
+	guardedTableNames := []string{originalTableName, newTableName}
 	if newTableName == "" {
+		// a generated name cannot be among the allowed tables, the copy of an
+		// allowed table is allowed
+		guardedTableNames = guardedTableNames[:1]
 		newTableName = fmt.Sprintf("%s-copy-%s", originalTableName, getRandomString(5))
 	}
 
-	createTableQuery, err := getQueryForDuplicateTableCreate(databaseType)
+	if err := checkGuard(o, guardedTableNames...); err != nil {
+		return DDLResult{}, fmt.Errorf("DuplicateTable - %w", err)
+	}
+
+	quotedOriginalTableName, err := quoteIdentifier(originalTableName, databaseType)
+	if err != nil {
+		return DDLResult{}, fmt.Errorf("DuplicateTable - %w", err)
+	}
+	quotedNewTableName, err := quoteIdentifier(newTableName, databaseType)
 	if err != nil {
-		return fmt.Errorf("unsupported database type for create table: %s", databaseType)
+		return DDLResult{}, fmt.Errorf("DuplicateTable - %w", err)
 	}
 
-	createQuery := fmt.Sprintf(createTableQuery, newTableName, originalTableName)
-	_, err = db.Exec(createQuery)
+	createTableQuery, err := getQueryForDuplicateTableCreate(databaseType)
 	if err != nil {
-		return fmt.Errorf("DuplicateTable: failed to create table structure: %v", err)
+		return DDLResult{}, fmt.Errorf("DuplicateTable - grabbing db type specific create query: %w", err)
 	}
 
-	insertDataQuery, err := getQueryForDuplicateTableInsert(databaseType)
+	// the statement reports a missing original or an existing copy, which
+	// checking the catalog first could miss
+	createQuery := fmt.Sprintf(createTableQuery, quotedNewTableName, quotedOriginalTableName)
+	_, err = execStatement(db, o, createQuery)
 	if err != nil {
-		return fmt.Errorf("unsupported database type for insert data: %s", databaseType)
+		err = classifyError(err)
+		if skipsDDLError(o, err) {
+			return DDLResult{}, nil
+		}
+		return DDLResult{}, fmt.Errorf("DuplicateTable: failed to create table structure: %w", err)
+	}
+	ResetColumnCache(db, newTableName)
+
+	if o.masking != nil {
+		err = copyMaskedRows(db, o, originalTableName, newTableName, databaseType)
+	} else {
+		var insertDataQuery string
+		insertDataQuery, err = getQueryForDuplicateTableInsert(databaseType)
+		if err == nil {
+			_, err = execStatement(db, o, fmt.Sprintf(insertDataQuery, quotedNewTableName, quotedOriginalTableName))
+			err = classifyError(err)
+		}
+	}
+	if err != nil {
+		// a half filled copy is worse than none
+		if dropErr := dropTable(db, o, quotedNewTableName, databaseType); dropErr != nil {
+			return DDLResult{Changed: true}, fmt.Errorf("DuplicateTable: failed to copy data into new table: %w (dropping the copy failed too: %v)", err, dropErr)
+		}
+		ResetColumnCache(db, newTableName)
+		return DDLResult{}, fmt.Errorf("DuplicateTable: failed to copy data into new table: %w", err)
 	}
 
-	insertQuery := fmt.Sprintf(insertDataQuery, newTableName, originalTableName)
-	_, err = db.Exec(insertQuery)
+	err = writeAudit(o, AuditEntry{Details: map[string]interface{}{"new_table": newTableName}})
 	if err != nil {
-		return fmt.Errorf("DuplicateTable: failed to insert data into new table: %v", err)
+		return DDLResult{Changed: true}, fmt.Errorf("DuplicateTable - %w", err)
 	}
 
-	return nil
+	return DDLResult{Changed: true}, nil
 }
 
-func DeleteTable(db *sql.DB, tableName string, databaseType DatabaseType) error {
+// Tells whether a failed DDL statement is the missing or existing table that
+// WithIfExists or WithIfNotExists turn into a no-op.
+func skipsDDLError(o *options, err error) bool {
+	return o.ifExists && errors.Is(err, ErrTableNotFound) || o.ifNotExists && errors.Is(err, ErrTableExists)
+}
+
+func dropTable(db *sql.DB, o *options, quotedTableName string, databaseType DatabaseType) error {
 	queryTemplate, err := getQueryForDeleteTable(databaseType)
 	if err != nil {
-		return fmt.Errorf("DeleteTable - grabbing db type specific query: %w", err)
+		return err
 	}
+	_, err = execStatement(db, o, fmt.Sprintf(queryTemplate, quotedTableName))
+	return classifyError(err)
+}
 
-	query := fmt.Sprintf(queryTemplate, tableName)
-	if databaseType == Oracle {
-		_, err := db.Exec(query)
+// Reads the rows of the original table, in no particular order, and inserts
+// them masked into the new table. A dry run plans the INSERT statement
+// without arguments, the rows only being read once the copy exists.
+func copyMaskedRows(db *sql.DB, o *options, originalTableName, newTableName string, dbType DatabaseType) error {
+	read := *o
+	read.columns, read.filters = nil, nil
+	read.codec = compareValueCodec
+
+	if o.dryRun != nil {
+		columns, err := GetColumns(db, originalTableName, dbType, inherit(&read))
+		if err != nil {
+			return err
+		}
+		query, err := renderInsert(newTableName, dbType, columns, 1)
 		if err != nil {
-			return fmt.Errorf("DeleteTable: failed to delete table %s: %v", tableName, err)
+			return err
 		}
+		o.dryRun.Statements = append(o.dryRun.Statements, Statement{SQL: query})
 		return nil
 	}
 
-	_, err = db.Exec(query)
+	// the copy has the columns of the original table
+	schemas, err := getMaskedColumnSchemas(db, &read, originalTableName, dbType)
 	if err != nil {
-		return fmt.Errorf("DeleteTable: failed to delete table %s: %v", tableName, err)
+		return err
+	}
+
+	rows, decodings, err := selectForExport(db, &read, originalTableName, dbType)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("retrieving columns: %w", err)
+	}
+
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	next := func() ([]interface{}, error) {
+		if !rows.Next() {
+			if err := rows.Err(); err != nil {
+				return nil, fmt.Errorf("rows iteration: %w", err)
+			}
+			return nil, io.EOF
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+
+		row := make([]interface{}, len(columns))
+		for i, column := range columns {
+			row[i], err = decodeColumnValue(values[i], decodings[i], compareValueCodec, &read)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", column, err)
+			}
+		}
+		if row, err = maskRow(o, originalTableName, columns, schemas, row); err != nil {
+			return nil, err
+		}
+		return encodeValues(o, row)
+	}
+
+	batchSize := o.batchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	_, err = insertBatches(db, o, newTableName, dbType, columns, batchSize, next)
+	return classifyError(err)
+}
+
+// DeleteTable drops the table. WithIfExists skips a missing table and
+// WithTrash moves the table to the trash instead. On Oracle a missing table
+// is skipped in any case.
+func DeleteTable(db *sql.DB, tableName string, databaseType DatabaseType, opts ...Option) (DDLResult, error) {
+	o := newOptions(db, OpDeleteTable, tableName, opts)
+
+	if err := checkGuard(o, tableName); err != nil {
+		return DDLResult{}, fmt.Errorf("DeleteTable - %w", err)
+	}
+
+	if o.trash {
+		result, err := trashTable(db, o, tableName, databaseType)
+		if err != nil {
+			return result, fmt.Errorf("DeleteTable - %w", err)
+		}
+		return result, nil
+	}
+
+	queryTemplate, err := getQueryForDeleteTable(databaseType)
+	if err != nil {
+		return DDLResult{}, fmt.Errorf("DeleteTable - grabbing db type specific query: %w", err)
+	}
+
+	quotedTableName, err := quoteIdentifier(tableName, databaseType)
+	if err != nil {
+		return DDLResult{}, fmt.Errorf("DeleteTable - %w", err)
+	}
+
+	if template, ok := getQueryForDeleteTableIfExists(databaseType); ok && o.ifExists {
+		// the check tells whether anything is dropped, IF EXISTS covers a
+		// table dropped in between
+		exists, err := tableExists(db, o, tableName, databaseType)
+		if err != nil {
+			return DDLResult{}, fmt.Errorf("DeleteTable - %w", err)
+		}
+		if !exists {
+			return DDLResult{}, nil
+		}
+		queryTemplate = template
+	}
+
+	query := fmt.Sprintf(queryTemplate, quotedTableName)
+
+	_, err = execStatement(db, o, query)
+	if err != nil {
+		err = classifyError(err)
+		if databaseType == Oracle && errors.Is(err, ErrTableNotFound) {
+			return DDLResult{}, nil
+		}
+		return DDLResult{}, fmt.Errorf("DeleteTable: failed to delete table %s: %w", tableName, err)
+	}
+	ResetColumnCache(db, tableName)
+
+	if err := writeAudit(o, AuditEntry{}); err != nil {
+		return DDLResult{Changed: true}, fmt.Errorf("DeleteTable - %w", err)
+	}
+
+	return DDLResult{Changed: true}, nil
+}
+
+// TruncateTable removes every record of the table while keeping its structure.
+// WithRestartIdentity also resets the identity / auto increment counters and
+// WithCascade truncates the tables referencing it, where the dialect allows.
+func TruncateTable(db *sql.DB, tableName string, databaseType DatabaseType, opts ...Option) error {
+	o := newOptions(db, OpTruncateTable, tableName, opts)
+
+	if err := checkGuard(o, tableName); err != nil {
+		return fmt.Errorf("TruncateTable - %w", err)
+	}
+
+	quotedTableName, err := quoteIdentifier(tableName, databaseType)
+	if err != nil {
+		return fmt.Errorf("TruncateTable - %w", err)
+	}
+
+	switch databaseType {
+	case PostgreSQL, CockroachDB, Oracle:
+	default:
+		if o.cascade {
+			return fmt.Errorf("TruncateTable: cascade is not supported on %s", databaseType)
+		}
+	}
+
+	switch databaseType {
+	case SQLite:
+		return truncateSQLiteTable(db, o, tableName, quotedTableName)
+	case MySQL, MariaDB, SQLServer:
+		// TRUNCATE always resets the counters on these engines, so a plain
+		// DELETE is used when the caller wants to keep them.
+		if !o.restartIdentity {
+			queryTemplate, err := getQueryForDeleteAllRecords(databaseType)
+			if err != nil {
+				return fmt.Errorf("TruncateTable - grabbing db type specific query: %w", err)
+			}
+			if _, err := execStatement(db, o, fmt.Sprintf(queryTemplate, quotedTableName)); err != nil {
+				return fmt.Errorf("TruncateTable: failed to truncate table %s: %w", tableName, classifyError(err))
+			}
+			return nil
+		}
+	case CockroachDB:
+		if o.restartIdentity {
+			return fmt.Errorf("TruncateTable: restarting identity is not supported on %s", databaseType)
+		}
+	}
+
+	queryTemplate, err := getQueryForTruncateTable(databaseType)
+	if err != nil {
+		return fmt.Errorf("TruncateTable - grabbing db type specific query: %w", err)
+	}
+
+	query := fmt.Sprintf(queryTemplate, quotedTableName)
+	if databaseType == PostgreSQL && o.restartIdentity {
+		query += " RESTART IDENTITY"
+	}
+	if o.cascade {
+		query += " CASCADE"
+	}
+
+	if _, err = execStatement(db, o, query); err != nil {
+		return fmt.Errorf("TruncateTable: failed to truncate table %s: %w", tableName, classifyError(err))
+	}
+
+	if databaseType == Oracle && o.restartIdentity {
+		if err := restartOracleIdentity(db, o, tableName, quotedTableName); err != nil {
+			return fmt.Errorf("TruncateTable: failed to restart identity of %s: %w", tableName, classifyError(err))
+		}
 	}
 
 	return nil
 }
 
-func RenameTable(db *sql.DB, oldTableName string, newTableName string, databaseType DatabaseType) error {
-	queryTemplate, err := getQueryForRenameTable(databaseType)
+// SQLite has no TRUNCATE, the counters of AUTOINCREMENT tables live in sqlite_sequence.
+func truncateSQLiteTable(db *sql.DB, o *options, tableName, quotedTableName string) error {
+	queryTemplate, err := getQueryForDeleteAllRecords(SQLite)
 	if err != nil {
-		return fmt.Errorf("RenameTable - grabbing db type specific query: %w", err)
+		return fmt.Errorf("TruncateTable - grabbing db type specific query: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("TruncateTable: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := execStatement(tx, o, fmt.Sprintf(queryTemplate, quotedTableName)); err != nil {
+		return fmt.Errorf("TruncateTable: failed to truncate table %s: %w", tableName, classifyError(err))
 	}
 
-	query := fmt.Sprintf(queryTemplate, oldTableName, newTableName)
+	if o.restartIdentity {
+		var hasSequence int
+		err := queryRow(tx, o, []interface{}{&hasSequence}, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'sqlite_sequence'")
+		if err != nil {
+			return fmt.Errorf("TruncateTable: failed to look up sqlite_sequence: %w", err)
+		}
+		if hasSequence > 0 {
+			if _, err := execStatement(tx, o, "DELETE FROM sqlite_sequence WHERE name = ?", tableName); err != nil {
+				return fmt.Errorf("TruncateTable: failed to reset sequence of %s: %w", tableName, classifyError(err))
+			}
+		}
+	}
 
-	_, err = db.Exec(query)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("TruncateTable: failed to commit: %w", err)
+	}
+
+	return nil
+}
+
+// Oracle has no RESTART IDENTITY clause, the identity column is redefined instead.
+func restartOracleIdentity(db *sql.DB, o *options, tableName, quotedTableName string) error {
+	rows, err := queryRows(
+		db, o,
+		"SELECT column_name, generation_type FROM user_tab_identity_cols WHERE table_name = :1",
+		tableName,
+	)
 	if err != nil {
-		return fmt.Errorf("RenameTable: could not rename table from %s to %s: %v", oldTableName, newTableName, err)
+		return err
+	}
+	defer rows.Close()
+
+	var statements []string
+	for rows.Next() {
+		var columnName, generationType string
+		if err := rows.Scan(&columnName, &generationType); err != nil {
+			return err
+		}
+		quotedColumnName, err := quoteIdentifier(columnName, Oracle)
+		if err != nil {
+			return err
+		}
+		statements = append(statements, fmt.Sprintf(
+			"ALTER TABLE %s MODIFY (%s GENERATED %s AS IDENTITY (START WITH 1))",
+			quotedTableName, quotedColumnName, generationType,
+		))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, statement := range statements {
+		if _, err := execStatement(db, o, statement); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
+
+// RenameTable renames the table. WithIfExists skips a missing table and
+// WithIfNotExists leaves both tables untouched when the new name is taken.
+func RenameTable(db *sql.DB, oldTableName string, newTableName string, databaseType DatabaseType, opts ...Option) (DDLResult, error) {
+	o := newOptions(db, OpRenameTable, oldTableName, opts)
+
+	if err := checkGuard(o, oldTableName, newTableName); err != nil {
+		return DDLResult{}, fmt.Errorf("RenameTable - %w", err)
+	}
+
+	queryTemplate, err := getQueryForRenameTable(databaseType)
+	if err != nil {
+		return DDLResult{}, fmt.Errorf("RenameTable - grabbing db type specific query: %w", err)
+	}
+
+	quotedOldTableName, err := quoteIdentifier(oldTableName, databaseType)
+	if err != nil {
+		return DDLResult{}, fmt.Errorf("RenameTable - %w", err)
+	}
+	quotedNewTableName, err := quoteIdentifier(newTableName, databaseType)
+	if err != nil {
+		return DDLResult{}, fmt.Errorf("RenameTable - %w", err)
+	}
+
+	query := fmt.Sprintf(queryTemplate, quotedOldTableName, quotedNewTableName)
+	var args []interface{}
+	if databaseType == SQLServer {
+		// sp_rename takes the old name as an identifier and the new one verbatim
+		query = queryTemplate
+		args = append(args, quotedOldTableName, newTableName)
+	}
+
+	// no dialect has IF EXISTS for both names, the errors of the statement
+	// tell a missing or existing table instead
+	_, err = execStatement(db, o, query, args...)
+	if err != nil {
+		err = classifyError(err)
+		if skipsDDLError(o, err) {
+			return DDLResult{}, nil
+		}
+		return DDLResult{}, fmt.Errorf("RenameTable: could not rename table from %s to %s: %w", oldTableName, newTableName, err)
+	}
+	ResetColumnCache(db, oldTableName, newTableName)
+
+	err = writeAudit(o, AuditEntry{Details: map[string]interface{}{"new_table": newTableName}})
+	if err != nil {
+		return DDLResult{Changed: true}, fmt.Errorf("RenameTable - %w", err)
+	}
+
+	return DDLResult{Changed: true}, nil
+}
@@ -0,0 +1,50 @@
+package sqlutils
+
+import (
+	"context"
+)
+
+// Span is the subset of an OpenTelemetry span used by TracingHook, small
+// enough to be implemented by a thin adapter over any tracing library.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts spans, like an OpenTelemetry tracer.
+type Tracer interface {
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// TracingHook wraps every statement into a span named after the operation.
+type TracingHook struct {
+	Tracer Tracer
+}
+
+// Keyed by hook so that several tracing hooks do not end each other's spans.
+type spanContextKey struct {
+	hook *TracingHook
+}
+
+func (h *TracingHook) BeforeQuery(ctx context.Context, event *QueryEvent) context.Context {
+	ctx, span := h.Tracer.Start(ctx, "sqlutils."+string(event.Operation))
+	span.SetAttribute("db.operation", string(event.Operation))
+	span.SetAttribute("db.sql.table", event.Table)
+	span.SetAttribute("db.statement", event.SQL)
+	return context.WithValue(ctx, spanContextKey{h}, span)
+}
+
+func (h *TracingHook) AfterQuery(ctx context.Context, event *QueryEvent) {
+	span, ok := ctx.Value(spanContextKey{h}).(Span)
+	if !ok {
+		return
+	}
+	if event.RowsAffected >= 0 {
+		span.SetAttribute("db.rows_affected", event.RowsAffected)
+	}
+	if event.Err != nil {
+		span.RecordError(event.Err)
+	}
+	span.End()
+}
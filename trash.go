@@ -0,0 +1,196 @@
+package sqlutils
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TrashPrefix starts the name of the tables moved to the trash, followed by
+// the time of the deletion and the original name:
+// _trash_20240131154502123_orders.
+const TrashPrefix = "_trash_"
+
+// Millisecond precision, so that a table deleted twice in a row does not
+// collide with its previous copy.
+const trashTimeLayout = "20060102150405.000"
+
+// TrashedTable is a table moved to the trash by DeleteTable.
+type TrashedTable struct {
+	Name         string
+	OriginalName string
+	TrashedAt    time.Time
+}
+
+// WithTrash makes DeleteTable rename the table into the trash instead of
+// dropping it, see ListTrashedTables, RestoreTable and PurgeTrash. Tables whose
+// trash name would be longer than the database allows fail with
+// ErrInvalidIdentifier and are left in place.
+func WithTrash() Option {
+	return func(o *options) {
+		o.trash = true
+	}
+}
+
+func trashTableName(tableName string, trashedAt time.Time) string {
+	timestamp := strings.Replace(trashedAt.UTC().Format(trashTimeLayout), ".", "", 1)
+	return TrashPrefix + timestamp + "_" + tableName
+}
+
+// Splits a trash table name, ok is false for the names that are not one.
+func parseTrashTableName(name string) (TrashedTable, bool) {
+	if !strings.HasPrefix(name, TrashPrefix) {
+		return TrashedTable{}, false
+	}
+
+	rest := name[len(TrashPrefix):]
+	separator := strings.IndexByte(rest, '_')
+	if separator != len(trashTimeLayout)-1 || separator == len(rest)-1 {
+		return TrashedTable{}, false
+	}
+
+	timestamp := rest[:separator-3] + "." + rest[separator-3:separator]
+	trashedAt, err := time.Parse(trashTimeLayout, timestamp)
+	if err != nil {
+		return TrashedTable{}, false
+	}
+
+	return TrashedTable{Name: name, OriginalName: rest[separator+1:], TrashedAt: trashedAt}, true
+}
+
+// Moves the table to the trash, called by DeleteTable with WithTrash.
+func trashTable(db *sql.DB, o *options, tableName string, databaseType DatabaseType) (DDLResult, error) {
+	trashedName := trashTableName(tableName, time.Now())
+	if err := checkIdentifierLength(trashedName, databaseType); err != nil {
+		return DDLResult{}, fmt.Errorf("moving table %s to the trash: %w", tableName, err)
+	}
+
+	result, err := RenameTable(db, tableName, trashedName, databaseType, inherit(o), withoutGuard(), withoutChangeTracking())
+	if err != nil {
+		return result, fmt.Errorf("moving table %s to the trash: %w", tableName, err)
+	}
+	if !result.Changed {
+		return result, nil
+	}
+
+	err = writeAudit(o, AuditEntry{Details: map[string]interface{}{"trashed_as": trashedName}})
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// ListTrashedTables returns the tables in the trash, oldest first.
+func ListTrashedTables(db *sql.DB, dbName string, dbType DatabaseType, opts ...Option) ([]TrashedTable, error) {
+	o := newOptions(db, OpListTrashedTables, "", opts)
+
+	if err := checkGuard(o); err != nil {
+		return nil, fmt.Errorf("ListTrashedTables - %w", err)
+	}
+
+	tableNames, err := GetTables(db, dbName, dbType, inherit(o), withoutGuard())
+	if err != nil {
+		return nil, fmt.Errorf("ListTrashedTables - %w", err)
+	}
+
+	var trashed []TrashedTable
+	for _, tableName := range tableNames {
+		if table, ok := parseTrashTableName(tableName); ok {
+			trashed = append(trashed, table)
+		}
+	}
+
+	sort.Slice(trashed, func(i, j int) bool {
+		if !trashed[i].TrashedAt.Equal(trashed[j].TrashedAt) {
+			return trashed[i].TrashedAt.Before(trashed[j].TrashedAt)
+		}
+		return trashed[i].Name < trashed[j].Name
+	})
+
+	return trashed, nil
+}
+
+// RestoreTable renames a table of the trash back to its original name. It
+// fails when a table with that name exists again, WithIfNotExists leaves both
+// tables untouched instead.
+func RestoreTable(db *sql.DB, trashedTableName string, dbType DatabaseType, opts ...Option) (DDLResult, error) {
+	table, ok := parseTrashTableName(trashedTableName)
+	if !ok {
+		return DDLResult{}, fmt.Errorf("RestoreTable - %s is not a trashed table: %w", trashedTableName, ErrTableNotFound)
+	}
+
+	o := newOptions(db, OpRestoreTable, table.OriginalName, opts)
+
+	if err := checkGuard(o, table.OriginalName); err != nil {
+		return DDLResult{}, fmt.Errorf("RestoreTable - %w", err)
+	}
+
+	result, err := RenameTable(db, trashedTableName, table.OriginalName, dbType, inherit(o), withoutGuard(), withoutChangeTracking())
+	if err != nil {
+		return result, fmt.Errorf("RestoreTable - %w", err)
+	}
+
+	if result.Changed {
+		err = writeAudit(o, AuditEntry{Details: map[string]interface{}{"restored_from": trashedTableName}})
+		if err != nil {
+			return result, fmt.Errorf("RestoreTable - %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// PurgeTrash drops the tables that have been in the trash for longer than
+// olderThan, zero purges the whole trash. It returns the names of the dropped
+// tables, including the ones dropped before an error.
+func PurgeTrash(db *sql.DB, dbName string, dbType DatabaseType, olderThan time.Duration, opts ...Option) ([]string, error) {
+	o := newOptions(db, OpPurgeTrash, "", opts)
+
+	trashed, err := ListTrashedTables(db, dbName, dbType, inherit(o), withoutGuard())
+	if err != nil {
+		return nil, fmt.Errorf("PurgeTrash - %w", err)
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+
+	var expired []TrashedTable
+	var originalNames []string
+	for _, table := range trashed {
+		if table.TrashedAt.Before(cutoff) {
+			expired = append(expired, table)
+			originalNames = append(originalNames, table.OriginalName)
+		}
+	}
+
+	if err := checkGuard(o, originalNames...); err != nil {
+		return nil, fmt.Errorf("PurgeTrash - %w", err)
+	}
+
+	var purged []string
+	for _, table := range expired {
+		tableOptions := newOptions(db, OpPurgeTrash, table.OriginalName, []Option{inherit(o)})
+
+		_, err := DeleteTable(db, table.Name, dbType, inherit(tableOptions), withoutGuard(), withoutChangeTracking(), withoutTrash())
+		if err != nil {
+			return purged, fmt.Errorf("PurgeTrash - %w", err)
+		}
+		purged = append(purged, table.Name)
+
+		err = writeAudit(tableOptions, AuditEntry{Details: map[string]interface{}{"purged": table.Name}})
+		if err != nil {
+			return purged, fmt.Errorf("PurgeTrash - %w", err)
+		}
+	}
+
+	return purged, nil
+}
+
+// Purging drops for good, whatever the options of the call.
+func withoutTrash() Option {
+	return func(o *options) {
+		o.trash = false
+	}
+}
@@ -22,3 +22,65 @@ type DBConnection struct {
 }
 
 type TableRecord map[string]interface{}
+
+// DDLResult reports whether a schema helper actually changed anything, which
+// is not the case when WithIfExists / WithIfNotExists short-circuited it.
+type DDLResult struct {
+	Changed bool
+}
+
+// Operation names a public helper of the package.
+type Operation string
+
+const (
+	OpGetTables         Operation = "GetTables"
+	OpGetTable          Operation = "GetTable"
+	OpGetColumns        Operation = "GetColumns"
+	OpGetPrimaryKeys    Operation = "GetPrimaryKeys"
+	OpInsertRecord      Operation = "InsertRecord"
+	OpDuplicateRecord   Operation = "DuplicateRecord"
+	OpEditRecord        Operation = "EditRecord"
+	OpRemoveRecord      Operation = "RemoveRecord"
+	OpCreateTable       Operation = "CreateTable"
+	OpDuplicateTable    Operation = "DuplicateTable"
+	OpDeleteTable       Operation = "DeleteTable"
+	OpTruncateTable     Operation = "TruncateTable"
+	OpRenameTable       Operation = "RenameTable"
+	OpUndo              Operation = "Undo"
+	OpListTrashedTables Operation = "ListTrashedTables"
+	OpRestoreTable      Operation = "RestoreTable"
+	OpPurgeTrash        Operation = "PurgeTrash"
+	OpExportTable       Operation = "ExportTable"
+	OpImportTable       Operation = "ImportTable"
+	OpDescribeTable     Operation = "DescribeTable"
+	OpGetForeignKeys    Operation = "GetForeignKeys"
+	OpDump              Operation = "Dump"
+	OpRestore           Operation = "Restore"
+	OpBulkLoad          Operation = "BulkLoad"
+	OpCompareTables     Operation = "CompareTables"
+	OpTableChecksum     Operation = "TableChecksum"
+	OpSyncTable         Operation = "SyncTable"
+	OpGenerateRows      Operation = "GenerateRows"
+	OpWriteAudit        Operation = "WriteAudit"
+)
+
+// Operations that change data or schema.
+var mutatingOperations = map[Operation]bool{
+	OpInsertRecord:    true,
+	OpDuplicateRecord: true,
+	OpEditRecord:      true,
+	OpRemoveRecord:    true,
+	OpCreateTable:     true,
+	OpDuplicateTable:  true,
+	OpDeleteTable:     true,
+	OpTruncateTable:   true,
+	OpRenameTable:     true,
+	OpUndo:            true,
+	OpRestoreTable:    true,
+	OpPurgeTrash:      true,
+	OpImportTable:     true,
+	OpRestore:         true,
+	OpBulkLoad:        true,
+	OpSyncTable:       true,
+	OpGenerateRows:    true,
+}
@@ -0,0 +1,243 @@
+package sqlutils
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RowChange is a row before and after a change. Before is nil for inserted
+// rows and After for removed ones.
+type RowChange struct {
+	Before TableRecord `json:"before,omitempty"`
+	After  TableRecord `json:"after,omitempty"`
+}
+
+// UndoToken holds what Undo needs to revert a record change. Rows are found
+// again by their primary key. The token can be stored as JSON as long as the
+// values of the rows survive the round trip.
+type UndoToken struct {
+	Operation    Operation    `json:"operation"`
+	Table        string       `json:"table"`
+	DatabaseType DatabaseType `json:"database_type"`
+	PrimaryKeys  []string     `json:"primary_keys,omitempty"`
+	Changes      []RowChange  `json:"changes"`
+}
+
+// WithUndo fills the token with the rows changed by InsertRecord,
+// DuplicateRecord, EditRecord or RemoveRecord. The rows are read in the
+// transaction of the change. Tables without a primary key fail with
+// ErrNoPrimaryKey before anything is changed, as their rows cannot be told
+// apart.
+func WithUndo(token *UndoToken) Option {
+	return func(o *options) {
+		o.undo = token
+	}
+}
+
+// Whether the call has to collect the rows it changes.
+func capturing(o *options) bool {
+	return o.dryRun == nil && (o.undo != nil || auditing(o))
+}
+
+// Refuses to collect an undo token for a table without a primary key.
+func checkUndoable(o *options, primaryKeys []string) error {
+	if o.undo != nil && o.dryRun == nil && len(primaryKeys) == 0 {
+		return fmt.Errorf("cannot undo changes of %s: %w", o.table, ErrNoPrimaryKey)
+	}
+	return nil
+}
+
+// Fills the undo token and writes the audit entries of a record change.
+func recordChanges(o *options, databaseType DatabaseType, primaryKeys []string, changes []RowChange) error {
+	if !capturing(o) {
+		return nil
+	}
+
+	if o.undo != nil {
+		*o.undo = UndoToken{
+			Operation:    o.operation,
+			Table:        o.table,
+			DatabaseType: databaseType,
+			PrimaryKeys:  primaryKeys,
+			Changes:      changes,
+		}
+	}
+
+	entries := make([]AuditEntry, len(changes))
+	for index, change := range changes {
+		row := change.Before
+		if row == nil {
+			row = change.After
+		}
+		entries[index] = AuditEntry{
+			PrimaryKey: primaryKeyOf(row, primaryKeys),
+			Before:     change.Before,
+			After:      change.After,
+		}
+	}
+
+	return writeAudit(o, entries...)
+}
+
+// Undo reverts the change recorded in the token, in a single transaction:
+// inserted rows are deleted, removed rows inserted again and edited rows get
+// their previous values back. It fails with ErrUndoConflict when a row is no
+// longer where the change left it. WithUndo on Undo itself gives a token
+// redoing the change.
+func Undo(db *sql.DB, token *UndoToken, opts ...Option) error {
+	if token == nil {
+		return fmt.Errorf("Undo - no undo token")
+	}
+	if len(token.PrimaryKeys) == 0 {
+		return fmt.Errorf("Undo - %w", ErrNoPrimaryKey)
+	}
+
+	o := newOptions(db, OpUndo, token.Table, opts)
+
+	if err := checkGuard(o, token.Table); err != nil {
+		return fmt.Errorf("Undo - %w", err)
+	}
+
+	quotedTableName, err := quoteIdentifier(token.Table, token.DatabaseType)
+	if err != nil {
+		return fmt.Errorf("Undo - %w", err)
+	}
+
+	reverted := make([]RowChange, 0, len(token.Changes))
+	err = inTransaction(db, o, true, func(e execer) error {
+		for index := len(token.Changes) - 1; index >= 0; index-- {
+			change := token.Changes[index]
+
+			var err error
+			switch {
+			case change.Before == nil && change.After == nil:
+				continue
+			case change.Before == nil:
+				err = undoInsert(e, o, quotedTableName, token, change.After)
+			case change.After == nil:
+				err = undoRemove(e, o, quotedTableName, token, change.Before)
+			default:
+				err = undoEdit(e, o, quotedTableName, token, change)
+			}
+			if err != nil {
+				return err
+			}
+
+			reverted = append(reverted, RowChange{Before: change.After, After: change.Before})
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Undo - %w", err)
+	}
+
+	if err := recordChanges(o, token.DatabaseType, token.PrimaryKeys, reverted); err != nil {
+		return fmt.Errorf("Undo - %w", err)
+	}
+
+	return nil
+}
+
+func undoInsert(e execer, o *options, quotedTableName string, token *UndoToken, inserted TableRecord) error {
+	conditions, args, err := identifyRow(inserted, token, 1)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", quotedTableName, conditions)
+	return execUndoStatement(e, o, query, args...)
+}
+
+func undoRemove(e execer, o *options, quotedTableName string, token *UndoToken, removed TableRecord) error {
+	keys, values := extractRecordData(removed)
+
+	quotedKeys, err := quoteIdentifiers(keys, token.DatabaseType)
+	if err != nil {
+		return err
+	}
+	placeholders, err := getPlaceholders(token.DatabaseType, 1, len(values))
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quotedTableName,
+		strings.Join(quotedKeys, ", "),
+		strings.Join(placeholders, ", "),
+	)
+
+	if _, err := execStatement(e, o, query, values...); err != nil {
+		return classifyError(err)
+	}
+	return nil
+}
+
+func undoEdit(e execer, o *options, quotedTableName string, token *UndoToken, change RowChange) error {
+	previous := make(TableRecord, len(change.Before))
+	for key, value := range change.Before {
+		if !reflect.DeepEqual(value, change.After[key]) {
+			previous[key] = value
+		}
+	}
+	if len(previous) == 0 {
+		return nil
+	}
+
+	keys, values := extractRecordData(previous)
+	assignments, err := computeAssignments(keys, token.DatabaseType, 1)
+	if err != nil {
+		return err
+	}
+
+	conditions, args, err := identifyRow(change.After, token, len(values)+1)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", quotedTableName, assignments, conditions)
+	return execUndoStatement(e, o, query, append(values, args...)...)
+}
+
+// Builds the conditions finding the row again, placeholders numbered from
+// position on.
+func identifyRow(row TableRecord, token *UndoToken, position int) (string, []interface{}, error) {
+	keys := token.PrimaryKeys
+
+	args := make([]interface{}, len(keys))
+	for index, key := range keys {
+		value, ok := row[key]
+		if !ok || value == nil {
+			return "", nil, fmt.Errorf("%w: %s", ErrPrimaryKeyMissing, key)
+		}
+		args[index] = value
+	}
+
+	conditions, err := computeConditions(keys, token.DatabaseType, position)
+	if err != nil {
+		return "", nil, err
+	}
+	return conditions, args, nil
+}
+
+// Runs an UPDATE or DELETE of the undo, which has to find its row.
+func execUndoStatement(e execer, o *options, query string, args ...interface{}) error {
+	result, err := execStatement(e, o, query, args...)
+	if err != nil {
+		return classifyError(err)
+	}
+	if o.dryRun != nil {
+		return nil
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return classifyError(err)
+	}
+	if rowsAffected == 0 {
+		return ErrUndoConflict
+	}
+
+	return nil
+}
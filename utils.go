@@ -1,8 +1,6 @@
 package sqlutils
 
 import (
-	"encoding/base64"
-	"regexp"
 	"runtime"
 	"time"
 	"unsafe"
@@ -10,29 +8,11 @@ import (
 	"golang.org/x/exp/rand"
 )
 
-func isBase64(b []byte) bool {
-	return len(b) > 0 && (b[len(b)-1] == '=' || b[len(b)-1] == '/')
-}
-
-func decodeBase64(b []byte) (string, error) {
-	decoded, err := base64.StdEncoding.DecodeString(string(b))
-	if err != nil {
-		return "", err
-	}
-	return string(decoded), nil
-}
-
 func getCurrentFuncName() string {
 	pc, _, _, _ := runtime.Caller(1)
 	return runtime.FuncForPC(pc).Name()
 }
 
-var validTableNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
-
-func isValidTableName(tableName string) bool {
-	return validTableNameRegex.MatchString(tableName)
-}
-
 const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 const (
 	letterIdxBits = 6                    // 6 bits to represent a letter index